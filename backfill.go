@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"time"
+
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/config"
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/registry"
+)
+
+// runBackfill records the stories of older list pages, e.g.
+//
+//	deshimula-notifier-unofficial backfill -source mula -pages 20 -count 200
+//	deshimula-notifier-unofficial backfill -source mula -since 2024-01-01
+func runBackfill(args []string) {
+	flags := flag.NewFlagSet("backfill", flag.ExitOnError)
+	source := flags.String("source", "", "source to backfill, defaults to all enabled sources")
+	pages := flags.Int("pages", config.BackfillPages, "number of list pages to walk")
+	count := flags.Int("count", 0, "stop after recording this many stories per source (0 = no limit)")
+	delay := flags.Duration("delay", config.BackfillDelay, "pause between two requests")
+	notify := flags.Bool("notify", false, "send backfilled stories to the notifiers")
+	since := flags.String("since", "", "stop at stories published before this date (2006-01-02 or RFC 3339)")
+	flags.Parse(args)
+
+	var sinceTime time.Time
+	if *since != "" {
+		var err error
+		if sinceTime, err = time.Parse("2006-01-02", *since); err != nil {
+			if sinceTime, err = time.Parse(time.RFC3339, *since); err != nil {
+				log.Fatalf("Invalid -since date: %s", *since)
+			}
+		}
+	}
+
+	lock := lockStorage()
+	defer lock.Unlock()
+
+	if err := registry.Load(); err != nil {
+		log.Fatalf("Failed to load sources: %v", err)
+	}
+
+	var names []string
+	if *source != "" {
+		names = []string{*source}
+	} else {
+		for _, name := range registry.EnabledNames() {
+			if def, exists := registry.Lookup(name); exists && def.PageURL == nil {
+				log.Printf("Skipping %s, its list is not paginated\n", name)
+				continue
+			}
+			names = append(names, name)
+		}
+	}
+
+	opts := registry.BackfillOptions{
+		MaxPages: *pages,
+		Count:    *count,
+		Delay:    *delay,
+		Notify:   *notify,
+		Since:    sinceTime,
+	}
+
+	for _, name := range names {
+		start := time.Now()
+		recorded, err := registry.Backfill(name, opts)
+		if err != nil {
+			log.Fatalf("Backfill of %s failed after %d stories: %v", name, recorded, err)
+		}
+		log.Printf("Backfilled %d %s stories in %s\n", recorded, name, time.Since(start).Round(time.Second))
+	}
+}
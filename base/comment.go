@@ -0,0 +1,131 @@
+package base
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"time"
+
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/config"
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/errorhandling"
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/storage"
+)
+
+// Comment is a reply posted under a story
+type Comment struct {
+	Author string `json:"author"`
+	Text   string `json:"text"`
+	// PostedAt is zero when the site shows no parsable date
+	PostedAt time.Time `json:"posted_at,omitzero"`
+}
+
+// Key identifies a comment in storage
+func (c *Comment) Key() string {
+	sum := sha256.Sum256([]byte(c.Author + "\x00" + c.Text))
+	return hex.EncodeToString(sum[:8])
+}
+
+// record returns the comment as storage keeps it
+func (c *Comment) record() storage.Comment {
+	return storage.Comment{Key: c.Key(), Author: c.Author, Text: c.Text, PostedAt: c.PostedAt}
+}
+
+// CheckComments re-fetches the stories posted within CommentWatchWindow, at
+// most once per config.CommentCheckInterval and config.RecheckLimit at a
+// time, least recently fetched first, and sends a comment event for every
+// comment that was not there before
+func (b *BaseService) CheckComments(parseStory func(string) (*Story, error)) {
+	if b.CommentWatchWindow <= 0 || time.Since(b.lastCommentCheck) < config.CommentCheckInterval {
+		return
+	}
+	b.lastCommentCheck = time.Now()
+
+	type watched struct {
+		id     string
+		record storage.Record
+	}
+	records, err := b.Storage.List()
+	if err != nil {
+		errorhandling.HandleError(errorhandling.NewError(errorhandling.StorageError, "Failed to list stories", err))
+		return
+	}
+
+	var stories []watched
+	for storyID, record := range records {
+		// Stories without a hash were never fetched, so their comments
+		// were never recorded either
+		if record.Link != "" && record.Hash != "" && record.RemovedAt == nil &&
+			time.Since(record.Posted()) < b.CommentWatchWindow {
+			stories = append(stories, watched{storyID, record})
+		}
+	}
+
+	sort.Slice(stories, func(i, j int) bool {
+		if !stories[i].record.ScrapedAt.Equal(stories[j].record.ScrapedAt) {
+			return stories[i].record.ScrapedAt.Before(stories[j].record.ScrapedAt)
+		}
+		return stories[i].id < stories[j].id
+	})
+	if len(stories) > config.RecheckLimit {
+		stories = stories[:config.RecheckLimit]
+	}
+
+	for _, w := range stories {
+		time.Sleep(b.recheckDelay)
+
+		story, err := parseStory(w.record.Link)
+		if err != nil {
+			errorhandling.HandleError(errorhandling.NewError(errorhandling.ScrapingError, "Failed to fetch comments of story "+w.id, err))
+			continue
+		}
+
+		seen := make(map[string]bool, len(w.record.Comments))
+		for _, sent := range w.record.Comments {
+			seen[sent.Key] = true
+		}
+
+		record := w.record
+		record.ScrapedAt = story.ScrapedAt
+		for i := range story.Comments {
+			comment := &story.Comments[i]
+			key := comment.Key()
+			if seen[key] {
+				continue
+			}
+
+			event := &Event{
+				Kind:    StoryComment,
+				Story:   story,
+				Comment: comment,
+				Detail:  describeComment(comment),
+				ReplyTo: w.record.Messages,
+			}
+			if err := b.NotifyEvent(event); err != nil {
+				errorhandling.HandleError(err)
+				break
+			}
+			seen[key] = true
+			record.Comments = append(record.Comments, comment.record())
+		}
+
+		// Saved even without new comments, ScrapedAt moves the story to
+		// the back of the queue
+		if err := b.Storage.Save(w.id, record); err != nil {
+			errorhandling.HandleError(errorhandling.NewError(errorhandling.StorageError, "Failed to save comments", err))
+		}
+
+		b.TrackVotes(w.id, story)
+	}
+}
+
+// describeComment renders a comment as the detail of its event
+func describeComment(c *Comment) string {
+	header := "Comment by " + c.Author
+	if c.Author == "" {
+		header = "New comment"
+	}
+	if !c.PostedAt.IsZero() {
+		header += " (" + c.PostedAt.UTC().Format("2 Jan 2006 15:04 UTC") + ")"
+	}
+	return header + ":\n\n" + c.Text
+}
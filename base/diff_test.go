@@ -0,0 +1,27 @@
+package base
+
+import "testing"
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		want     string
+	}{
+		{"unchanged", "a\nb", "a\nb", ""},
+		{"added", "a\nc", "a\nb\nc", "+ b"},
+		{"removed", "a\nb\nc", "a\nc", "- b"},
+		{"changed", "a\nb\nc", "a\nB\nc", "- b\n+ B"},
+		{"appended and dropped", "a\nb", "b\nc", "- a\n+ c"},
+		{"blank lines are left out", "a\nb", "a\n\nb\n", ""},
+		{"from empty", "", "a", "+ a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diffLines(tt.old, tt.new); got != tt.want {
+				t.Errorf("diffLines = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
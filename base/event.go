@@ -0,0 +1,51 @@
+package base
+
+// EventKind is the kind of change a notification is about
+type EventKind string
+
+const (
+	// StoryUpdated is sent when a story was edited after it was sent
+	StoryUpdated EventKind = "updated"
+	// StoryRemoved is sent when a story was taken down after it was sent
+	StoryRemoved EventKind = "removed"
+	// StoryComment is sent for every new comment on a watched story
+	StoryComment EventKind = "comment"
+	// StoryTrending is sent once when a story gains votes quickly
+	StoryTrending EventKind = "trending"
+)
+
+// Event is a change to a story that was already sent
+type Event struct {
+	Kind  EventKind `json:"kind"`
+	Story *Story    `json:"story"`
+	// Comment is the new comment of a comment event
+	Comment *Comment `json:"comment,omitempty"`
+	// Detail describes the change, e.g. a diff of the description
+	Detail string `json:"detail,omitempty"`
+	// ReplyTo maps notifier names to the message the story was sent as,
+	// ThreadNotifiers post the event as a reply to it
+	ReplyTo map[string]string `json:"-"`
+}
+
+// EventNotifier is implemented by notifiers that handle events themselves.
+// Other notifiers receive the story returned by Event.AsStory.
+type EventNotifier interface {
+	SendEvent(event *Event) error
+}
+
+// AsStory renders the event as a story, so every notifier can deliver it
+func (e *Event) AsStory() *Story {
+	story := *e.Story
+	switch e.Kind {
+	case StoryUpdated:
+		story.Title = "✏️ Updated: " + e.Story.Title
+	case StoryRemoved:
+		story.Title = "🗑️ Removed: " + e.Story.Title
+	case StoryComment:
+		story.Title = "💬 New comment: " + e.Story.Title
+	case StoryTrending:
+		story.Title = "🔥 Trending: " + e.Story.Title
+	}
+	story.Description = e.Detail
+	return &story
+}
@@ -0,0 +1,54 @@
+package base
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Fetch sends a GET request with the configured browser headers. The caller
+// must close the response body.
+func (b *BaseService) Fetch(url string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, value := range b.HTTPConfig.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := b.HTTPConfig.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, &StatusError{Code: resp.StatusCode}
+	}
+	return resp, nil
+}
+
+// StatusError is returned by Fetch for non-200 responses
+type StatusError struct {
+	Code int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d", e.Code)
+}
+
+// ErrStoryNotFound is returned by story parsers when the page loaded but
+// does not contain a story. Taken down stories often look like this, but so
+// does a markup change, see BaseService.CheckRemovedStories.
+var ErrStoryNotFound = errors.New("story not found")
+
+// IsStoryGone reports whether err is an explicit 404 or 410 response
+func IsStoryGone(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Code == http.StatusNotFound || statusErr.Code == http.StatusGone
+	}
+	return false
+}
@@ -0,0 +1,38 @@
+package base
+
+// Notifier delivers stories to a single destination (Discord, Slack, ...)
+type Notifier interface {
+	// Name identifies the notifier in logs and error messages
+	Name() string
+	// Send delivers a story
+	Send(story *Story) error
+	// SendError delivers a formatted error report
+	SendError(message string) error
+	// Health reports whether the destination is reachable and configured
+	Health() error
+}
+
+// PassiveNotifier is implemented by notifiers that only record stories
+// locally (feeds, logs). A passive notifier accepting a story does not mark
+// it as sent, unless no other notifier is configured.
+type PassiveNotifier interface {
+	Passive() bool
+}
+
+// ThreadNotifier is implemented by notifiers that can post follow-ups as
+// replies to the message a story was sent as (a Discord thread, a Slack
+// thread, a Telegram reply)
+type ThreadNotifier interface {
+	// SendThreaded delivers a story like Send and returns a reference to
+	// its message, empty when the destination cannot be replied to
+	SendThreaded(story *Story) (string, error)
+	// Reply delivers an event as a reply to the message returned by
+	// SendThreaded
+	Reply(ref string, event *Event) error
+}
+
+// isPassive reports whether n is a passive notifier
+func isPassive(n Notifier) bool {
+	p, ok := n.(PassiveNotifier)
+	return ok && p.Passive()
+}
@@ -0,0 +1,143 @@
+package base
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/storage"
+)
+
+type fakeNotifier struct {
+	name    string
+	passive bool
+	err     error
+	sent    int
+}
+
+func (f *fakeNotifier) Name() string                   { return f.name }
+func (f *fakeNotifier) Send(story *Story) error        { f.sent++; return f.err }
+func (f *fakeNotifier) SendError(message string) error { return nil }
+func (f *fakeNotifier) Health() error                  { return nil }
+func (f *fakeNotifier) Passive() bool                  { return f.passive }
+
+func TestNotifyIgnoresPassiveNotifiers(t *testing.T) {
+	failed := errors.New("unavailable")
+	tests := []struct {
+		name      string
+		notifiers []*fakeNotifier
+		wantErr   bool
+	}{
+		{"active ok", []*fakeNotifier{{name: "discord"}}, false},
+		{"active failed", []*fakeNotifier{{name: "discord", err: failed}}, true},
+		{"one active ok", []*fakeNotifier{{name: "discord", err: failed}, {name: "slack"}}, false},
+		{"only passive ok", []*fakeNotifier{{name: "discord", err: failed}, {name: "jsonl", passive: true}}, true},
+		{"passive alone", []*fakeNotifier{{name: "jsonl", passive: true}}, false},
+		{"passive alone failed", []*fakeNotifier{{name: "jsonl", passive: true, err: failed}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var notifiers []Notifier
+			for _, n := range tt.notifiers {
+				notifiers = append(notifiers, n)
+			}
+			b := NewBaseService(storage.NewMemoryStore(), "https://example.com", notifiers)
+
+			_, err := b.Notify(&Story{Company: "Company", Description: "Description"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Notify = %v, want error %v", err, tt.wantErr)
+			}
+			for _, n := range tt.notifiers {
+				if n.sent != 1 {
+					t.Errorf("%s received %d stories, want 1", n.name, n.sent)
+				}
+			}
+		})
+	}
+}
+
+// threadNotifier records the replies it receives
+type threadNotifier struct {
+	fakeNotifier
+	replies []string
+}
+
+func (t *threadNotifier) SendThreaded(story *Story) (string, error) {
+	t.sent++
+	return "message-1", t.err
+}
+
+func (t *threadNotifier) Reply(ref string, event *Event) error {
+	t.replies = append(t.replies, ref)
+	return nil
+}
+
+func TestCommentsAreThreadedUnderTheStory(t *testing.T) {
+	threaded := &threadNotifier{fakeNotifier: fakeNotifier{name: "telegram"}}
+	plain := &fakeNotifier{name: "discord"}
+	b := NewBaseService(storage.NewMemoryStore(), "https://example.com", []Notifier{threaded, plain})
+
+	story := &Story{Company: "Company", Description: "Description", Link: "https://example.com/story/1"}
+	messages, err := b.Notify(story)
+	if err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if err := b.SaveStory("1", story, messages); err != nil {
+		t.Fatalf("SaveStory: %v", err)
+	}
+
+	record, _, _ := b.Storage.Get("1")
+	if record.Messages["telegram"] != "message-1" || len(record.Messages) != 1 {
+		t.Fatalf("stored messages = %v, want only the telegram message", record.Messages)
+	}
+
+	event := &Event{Kind: StoryComment, Story: story, ReplyTo: record.Messages}
+	if err := b.NotifyEvent(event); err != nil {
+		t.Fatalf("NotifyEvent: %v", err)
+	}
+	if len(threaded.replies) != 1 || threaded.replies[0] != "message-1" {
+		t.Errorf("replies = %v, want one reply to message-1", threaded.replies)
+	}
+	if plain.sent != 2 {
+		t.Errorf("plain notifier received %d messages, want the story and the event", plain.sent)
+	}
+}
+
+// unreadableStore fails every lookup, like a database that went away
+type unreadableStore struct {
+	*storage.MemoryStore
+}
+
+func (s unreadableStore) Has(id string) (bool, error) {
+	return false, errors.New("disk I/O error")
+}
+
+func (s unreadableStore) Get(id string) (storage.Record, bool, error) {
+	return storage.Record{}, false, errors.New("disk I/O error")
+}
+
+func TestUnreadableStoreStopsDelivery(t *testing.T) {
+	store := unreadableStore{storage.NewMemoryStore()}
+	sent := storage.Record{Hash: "hash", Messages: map[string]string{"discord": "1"}}
+	if err := store.Save("1", sent); err != nil {
+		t.Fatal(err)
+	}
+	notifier := &fakeNotifier{name: "discord"}
+	b := NewBaseService(store, "https://example.com", []Notifier{notifier})
+
+	parsed := false
+	err := b.ProcessStory("https://example.com/story/1", func(string) (*Story, error) {
+		parsed = true
+		return &Story{Link: "https://example.com/story/1"}, nil
+	})
+	if err == nil || parsed || notifier.sent != 0 {
+		t.Errorf("ProcessStory = %v, parsed %v, sent %d; want an error before fetching", err, parsed, notifier.sent)
+	}
+
+	if err := b.SaveStory("1", &Story{Title: "Title"}, nil); err == nil {
+		t.Error("SaveStory succeeded without reading the stored record")
+	}
+	if records, _ := store.List(); records["1"].Hash != "hash" || records["1"].Messages["discord"] != "1" {
+		t.Errorf("stored record = %+v, want it unchanged", records["1"])
+	}
+}
@@ -0,0 +1,115 @@
+package base
+
+import (
+	"log"
+	"sort"
+	"time"
+
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/config"
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/errorhandling"
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/storage"
+)
+
+// Retention limits how many stories the storage keeps
+type Retention struct {
+	// MaxAge prunes stories first seen longer ago, 0 means no limit
+	MaxAge time.Duration
+	// MaxCount prunes the oldest stories beyond this count, 0 means no
+	// limit
+	MaxCount int
+}
+
+// Enabled reports whether the retention prunes anything at all
+func (r Retention) Enabled() bool {
+	return r.MaxAge > 0 || r.MaxCount > 0
+}
+
+// PrunedStory is a story removed (or, in a dry run, to be removed) from
+// storage
+type PrunedStory struct {
+	ID     string
+	Record storage.Record
+}
+
+// Prune removes the stories the retention does not keep, oldest first.
+// Stories listed in links are always kept, pruning them would send them
+// again on the next check. With dryRun nothing is deleted.
+func (b *BaseService) Prune(retention Retention, links []string, dryRun bool) ([]PrunedStory, error) {
+	if !retention.Enabled() {
+		return nil, nil
+	}
+
+	records, err := b.Storage.List()
+	if err != nil {
+		return nil, err
+	}
+
+	listed := make(map[string]bool, len(links))
+	for _, link := range links {
+		listed[b.StoryID(link)] = true
+	}
+
+	stories := make([]PrunedStory, 0, len(records))
+	for id, record := range records {
+		stories = append(stories, PrunedStory{ID: id, Record: record})
+	}
+	// Newest first, so everything after MaxCount is the oldest
+	sort.Slice(stories, func(i, j int) bool {
+		if !stories[i].Record.FirstSeen.Equal(stories[j].Record.FirstSeen) {
+			return stories[i].Record.FirstSeen.After(stories[j].Record.FirstSeen)
+		}
+		return stories[i].ID < stories[j].ID
+	})
+
+	var pruned []PrunedStory
+	for i, story := range stories {
+		tooOld := retention.MaxAge > 0 && time.Since(story.Record.FirstSeen) > retention.MaxAge
+		tooMany := retention.MaxCount > 0 && i >= retention.MaxCount
+		if (tooOld || tooMany) && !listed[story.ID] {
+			pruned = append(pruned, story)
+		}
+	}
+
+	// Report the oldest first
+	for i, j := 0, len(pruned)-1; i < j; i, j = i+1, j-1 {
+		pruned[i], pruned[j] = pruned[j], pruned[i]
+	}
+
+	if dryRun {
+		return pruned, nil
+	}
+	for i, story := range pruned {
+		if err := b.Storage.Delete(story.ID); err != nil {
+			return pruned[:i], err
+		}
+	}
+	return pruned, nil
+}
+
+// PruneStories applies the configured retention at most once per
+// config.PruneInterval, keeping the stories listLinks returns (the same list
+// pages as the prune command)
+func (b *BaseService) PruneStories(listLinks func() ([]string, error)) {
+	if !b.Retention.Enabled() || time.Since(b.lastPrune) < config.PruneInterval {
+		return
+	}
+	b.lastPrune = time.Now()
+
+	links, err := listLinks()
+	if err != nil {
+		errorhandling.HandleError(errorhandling.NewError(errorhandling.ScrapingError, "Failed to list the stories to keep, not pruning", err))
+		return
+	}
+	// An empty list would leave nothing protected
+	if len(links) == 0 {
+		return
+	}
+
+	pruned, err := b.Prune(b.Retention, links, false)
+	if err != nil {
+		errorhandling.HandleError(errorhandling.NewError(errorhandling.StorageError, "Failed to prune stories", err))
+	}
+	if len(pruned) > 0 {
+		log.Printf("Pruned %d %s stories\n", len(pruned), b.Source)
+	}
+}
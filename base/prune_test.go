@@ -0,0 +1,112 @@
+package base
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/storage"
+)
+
+// newPruneService stores stories "1" to "5", "1" first seen n days ago and
+// "5" today
+func newPruneService(t *testing.T) *BaseService {
+	t.Helper()
+	b := NewBaseService(storage.NewMemoryStore(), "https://example.com", nil)
+	now := time.Now().UTC()
+	for days := 0; days < 5; days++ {
+		id := string(rune('5' - days))
+		if err := b.Storage.Save(id, storage.Record{FirstSeen: now.Add(-time.Duration(days)*24*time.Hour - time.Hour)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return b
+}
+
+// storedIDs returns the sorted IDs left in storage
+func storedIDs(t *testing.T, b *BaseService) string {
+	t.Helper()
+	records, err := b.Storage.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for id := range records {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return strings.Join(ids, " ")
+}
+
+func TestPrune(t *testing.T) {
+	tests := []struct {
+		name       string
+		retention  Retention
+		listed     []string
+		dryRun     bool
+		wantPruned string
+		wantKept   string
+	}{
+		{"disabled", Retention{}, nil, false, "", "1 2 3 4 5"},
+		{"max age", Retention{MaxAge: 48 * time.Hour}, nil, false, "1 2 3", "4 5"},
+		{"max count", Retention{MaxCount: 3}, nil, false, "1 2", "3 4 5"},
+		{"max age and count", Retention{MaxAge: 72 * time.Hour, MaxCount: 2}, nil, false, "1 2 3", "4 5"},
+		{"listed stories are kept", Retention{MaxCount: 1}, []string{"https://example.com/story/1", "https://example.com/story/3"}, false, "2 4", "1 3 5"},
+		{"dry run", Retention{MaxCount: 1}, nil, true, "1 2 3 4", "1 2 3 4 5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newPruneService(t)
+
+			pruned, err := b.Prune(tt.retention, tt.listed, tt.dryRun)
+			if err != nil {
+				t.Fatalf("Prune: %v", err)
+			}
+
+			// Reported oldest first
+			var ids []string
+			for _, story := range pruned {
+				ids = append(ids, story.ID)
+			}
+			if got := strings.Join(ids, " "); got != tt.wantPruned {
+				t.Errorf("pruned %q, want %q", got, tt.wantPruned)
+			}
+			if got := storedIDs(t, b); got != tt.wantKept {
+				t.Errorf("kept %q, want %q", got, tt.wantKept)
+			}
+		})
+	}
+}
+
+func TestPruneStoriesNeedsTheList(t *testing.T) {
+	tests := []struct {
+		name  string
+		links []string
+		err   error
+	}{
+		{"list fetch failed", nil, errors.New("unavailable")},
+		{"empty list", nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newPruneService(t)
+			b.Retention = Retention{MaxCount: 1}
+
+			b.PruneStories(func() ([]string, error) { return tt.links, tt.err })
+			if got := storedIDs(t, b); got != "1 2 3 4 5" {
+				t.Errorf("kept %q, want every story", got)
+			}
+		})
+	}
+
+	b := newPruneService(t)
+	b.Retention = Retention{MaxCount: 1}
+	b.PruneStories(func() ([]string, error) { return []string{"https://example.com/story/2"}, nil })
+	if got := storedIDs(t, b); got != "2 5" {
+		t.Errorf("kept %q, want the newest and the listed story", got)
+	}
+}
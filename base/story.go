@@ -1,57 +1,86 @@
 package base
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"path/filepath"
+	"log"
+	"maps"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/nahidhasan98/deshimula-notifier-unofficial/config"
 	"github.com/nahidhasan98/deshimula-notifier-unofficial/errorhandling"
 	"github.com/nahidhasan98/deshimula-notifier-unofficial/storage"
-	discordtexthook "github.com/nahidhasan98/discord-text-hook"
 )
 
 // Story represents a common story structure
 type Story struct {
-	Title       string
-	Company     string
-	Tag         string
-	Description string
-	Link        string
-	Author      string
+	// ID is the storage ID of the story, see BaseService.StoryID
+	ID string `json:"id"`
+	// Source is the name of the source the story was scraped from
+	Source      string `json:"source"`
+	Title       string `json:"title"`
+	Company     string `json:"company"`
+	Tag         string `json:"tag"`
+	Description string `json:"description"`
+	Link        string `json:"link"`
+	Author      string `json:"author"`
+	// Comments are only filled by sources that scrape replies
+	Comments []Comment `json:"comments,omitempty"`
+	// Votes is the upvote or reaction count shown on the site
+	Votes int `json:"votes,omitempty"`
+	// PublishedAt is zero when the site shows no parsable date
+	PublishedAt time.Time `json:"published_at,omitzero"`
+	ScrapedAt   time.Time `json:"scraped_at"`
 }
 
 // BaseService provides common functionality for story services
 type BaseService struct {
-	HTTPConfig   *config.HTTPConfig
-	Storage      *storage.StoryStorage
-	mu           sync.Mutex
-	discordMu    sync.Mutex
-	BaseURL      string
-	WebhookID    string
-	WebhookToken string
-	EmbedColor   int
-	isFirstRun   bool
+	HTTPConfig *config.HTTPConfig
+	Storage    storage.Store
+	Notifiers  []Notifier
+	mu         sync.Mutex
+	notifyMu   sync.Mutex
+	BaseURL    string
+	// Source is the name of the source, set on every story
+	Source string
+	// IDFromLink extracts the story ID from a link, defaults to the part
+	// after "<BaseURL>/story/"
+	IDFromLink  func(link string) string
+	isFirstRun  bool
+	lastRecheck time.Time
+	// lastRemovalCheck is when CheckRemovedStories last ran
+	lastRemovalCheck time.Time
+	// CommentWatchWindow is how long after a story was posted its
+	// comments are checked, 0 disables comment checks
+	CommentWatchWindow time.Duration
+	lastCommentCheck   time.Time
+	// TrendingVelocity is the number of votes per hour that makes a story
+	// trending, 0 disables trending events
+	TrendingVelocity float64
+	// TrendingWindow is how long after posting a story can become trending
+	TrendingWindow time.Duration
+	// Retention is applied by PruneStories
+	Retention Retention
+	lastPrune time.Time
+	// recheckDelay is the pause between two re-fetches of stored stories
+	recheckDelay time.Duration
 }
 
 // NewBaseService creates a new base service
-func NewBaseService(storageFile string, baseURL string, webhookID string, webhookToken string, embedColor int) (*BaseService, error) {
-	storageDir := filepath.Join(config.StorageDir, storageFile)
-	storyStorage, err := storage.NewStoryStorage(storageDir)
-	if err != nil {
-		return nil, errorhandling.NewError(errorhandling.ConfigError, "Failed to initialize storage", err)
-	}
-
+func NewBaseService(store storage.Store, baseURL string, notifiers []Notifier) *BaseService {
 	return &BaseService{
 		HTTPConfig:   config.NewHTTPConfig(),
-		Storage:      storyStorage,
+		Storage:      store,
+		Notifiers:    notifiers,
 		BaseURL:      baseURL,
-		WebhookID:    webhookID,
-		WebhookToken: webhookToken,
-		EmbedColor:   embedColor,
 		isFirstRun:   true,
-	}, nil
+		recheckDelay: config.RecheckDelay,
+	}
 }
 
 // FetchAndProcessStories is the common implementation for fetching and processing stories
@@ -74,8 +103,13 @@ func (b *BaseService) FetchAndProcessStories(fetchLinks func() ([]string, error)
 			}()
 
 			for _, link := range links[1:] {
-				storyID := strings.TrimPrefix(link, b.BaseURL+"/story/")
-				if !b.HasStory(storyID) {
+				storyID := b.StoryID(link)
+				exists, err := b.HasStory(storyID)
+				if err != nil {
+					errorhandling.HandleError(err)
+					continue
+				}
+				if !exists {
 					if err := b.AddStory(storyID); err != nil {
 						errorhandling.HandleError(err)
 					}
@@ -99,101 +133,415 @@ func (b *BaseService) FetchAndProcessStories(fetchLinks func() ([]string, error)
 	return nil
 }
 
-// SendToDiscord sends a story to Discord
-func (b *BaseService) SendToDiscord(story *Story) error {
-	// Validate required fields
-	if story.Company == "" {
-		return errorhandling.NewError(errorhandling.ValidationError, "Cannot send story with empty company name", nil)
+// ProcessStory parses and sends a story that has not been sent yet
+func (b *BaseService) ProcessStory(link string, parseStory func(string) (*Story, error)) error {
+	storyID := b.StoryID(link)
+	exists, err := b.HasStory(storyID)
+	if err != nil {
+		return err
 	}
-	if story.Description == "" {
-		return errorhandling.NewError(errorhandling.ValidationError, "Cannot send story with empty description", nil)
+	if exists {
+		log.Println("Found no new story, skipping:", storyID)
+		return nil
 	}
 
-	b.discordMu.Lock()
-	defer b.discordMu.Unlock()
+	story, err := parseStory(link)
+	if err != nil {
+		return errorhandling.NewError(errorhandling.ScrapingError, "Failed to fetch story", err)
+	}
 
-	webhook := discordtexthook.NewDiscordTextHookService(b.WebhookID, b.WebhookToken)
+	messages, err := b.Notify(story)
+	if err != nil {
+		return err
+	}
 
-	embed := discordtexthook.Embed{
-		Title: "📢  " + truncateString(story.Title, 256),
-		Description: fmt.Sprintf("**Author:** %s\n**Company:** %s\n**Tag:** %s\n**Link:** %s",
-			truncateString(story.Author, 1024),
-			truncateString(story.Company, 1024),
-			truncateString(story.Tag, 1024),
-			story.Link),
-		Color: b.EmbedColor,
+	if err := b.SaveStory(storyID, story, messages); err != nil {
+		return errorhandling.NewError(errorhandling.StorageError, "Failed to mark story as sent", err)
 	}
+	b.TrackVotes(storyID, story)
+	return nil
+}
 
-	if _, err := webhook.SendEmbed(embed); err != nil {
-		return errorhandling.NewError(errorhandling.DiscordError, "Failed to send main embed to Discord", err)
+// RecheckStories re-fetches the already sent stories among links, at most
+// once per config.RecheckInterval, and sends an update event for every story
+// whose content changed since it was sent. Vote counts are tracked on every
+// re-fetch.
+func (b *BaseService) RecheckStories(links []string, parseStory func(string) (*Story, error)) {
+	if time.Since(b.lastRecheck) < config.RecheckInterval {
+		return
 	}
+	b.lastRecheck = time.Now()
+
+	checked := 0
+	for _, link := range links {
+		if checked >= config.RecheckLimit {
+			break
+		}
+
+		storyID := b.StoryID(link)
+		record, exists, err := b.Storage.Get(storyID)
+		if err != nil {
+			errorhandling.HandleError(errorhandling.NewError(errorhandling.StorageError, "Failed to read story "+storyID, err))
+			continue
+		}
+		if !exists {
+			continue
+		}
+		checked++
+
+		time.Sleep(b.recheckDelay)
+
+		story, err := parseStory(link)
+		if err != nil {
+			errorhandling.HandleError(errorhandling.NewError(errorhandling.ScrapingError, "Failed to re-fetch story "+storyID, err))
+			continue
+		}
 
-	const maxContentLength = 4000
-	description := story.Description
-	chunkNumber := 1
+		b.TrackVotes(storyID, story)
 
-	for len(description) > 0 {
-		chunk := description
-		if len(description) > maxContentLength {
-			lastNewline := strings.LastIndex(description[:maxContentLength], "\n")
-			if lastNewline == -1 {
-				// If no newline found, cut at maxLength
-				chunk = description[:maxContentLength]
-				description = description[maxContentLength:]
-			} else {
-				// Cut at the last newline
-				chunk = description[:lastNewline]
-				description = description[lastNewline+1:] // Skip the newline character
+		hash := story.ContentHash()
+		if hash == record.Hash {
+			continue
+		}
+
+		// Stories marked as seen without being fetched have no hash yet,
+		// whitespace-only edits have nothing to show
+		if changes := describeChanges(record, story); record.Hash != "" && changes != "" {
+			event := &Event{
+				Kind:   StoryUpdated,
+				Story:  story,
+				Detail: changes,
 			}
-		} else {
-			// This is the last chunk
-			chunk = description
-			description = ""
+			if err := b.NotifyEvent(event); err != nil {
+				errorhandling.HandleError(err)
+				continue
+			}
+		}
+
+		if err := b.SaveStory(storyID, story, nil); err != nil {
+			errorhandling.HandleError(errorhandling.NewError(errorhandling.StorageError, "Failed to update story", err))
 		}
+	}
+}
+
+// CheckRemovedStories re-fetches the stored stories that are no longer on
+// the list page, at most once per config.RemovalCheckInterval and
+// config.RecheckLimit at a time, least recently checked first. Stories are
+// watched for config.RemovalWatchWindow after they were posted, so one taken
+// down after it scrolled off the list is still detected. A 404/410 counts as
+// removed, and so does a page without the story (ErrStoryNotFound) for
+// config.RemovalNotFoundChecks checks in a row, as long as a listed story
+// still parses, which rules out a markup change. A story failing
+// config.RemovalMaxErrors checks in a row for another reason is no longer
+// checked.
+func (b *BaseService) CheckRemovedStories(links []string, parseStory func(string) (*Story, error)) {
+	// An empty list page is more likely a broken page than a mass removal
+	if len(links) == 0 || time.Since(b.lastRemovalCheck) < config.RemovalCheckInterval {
+		return
+	}
+	b.lastRemovalCheck = time.Now()
+
+	listed := make(map[string]bool, len(links))
+	for _, link := range links {
+		listed[b.StoryID(link)] = true
+	}
+
+	records, err := b.Storage.List()
+	if err != nil {
+		errorhandling.HandleError(errorhandling.NewError(errorhandling.StorageError, "Failed to list stories", err))
+		return
+	}
 
-		// Skip empty chunks
-		chunk = strings.TrimSpace(chunk)
-		if chunk == "" {
+	type candidate struct {
+		id     string
+		record storage.Record
+	}
+	var candidates []candidate
+	for storyID, record := range records {
+		if listed[storyID] || record.Link == "" || record.RemovedAt != nil ||
+			record.RemovalErrors >= config.RemovalMaxErrors ||
+			time.Since(record.Posted()) > config.RemovalWatchWindow {
 			continue
 		}
+		candidates = append(candidates, candidate{storyID, record})
+	}
 
-		var title string
-		if chunkNumber > 1 || len(description) > 0 {
-			title = fmt.Sprintf("Review/Description (Part %d)", chunkNumber)
-		} else {
-			title = "Review/Description"
+	lastChecked := func(c candidate) time.Time {
+		if c.record.RemovalCheckedAt == nil {
+			return time.Time{}
+		}
+		return *c.record.RemovalCheckedAt
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if !lastChecked(candidates[i]).Equal(lastChecked(candidates[j])) {
+			return lastChecked(candidates[i]).Before(lastChecked(candidates[j]))
+		}
+		return candidates[i].id < candidates[j].id
+	})
+	if len(candidates) > config.RecheckLimit {
+		candidates = candidates[:config.RecheckLimit]
+	}
+
+	// Parsed at most once, and only if a story is not found
+	var listParses *bool
+	parserWorks := func() bool {
+		if listParses == nil {
+			time.Sleep(b.recheckDelay)
+			_, err := parseStory(links[0])
+			works := err == nil
+			listParses = &works
+		}
+		return *listParses
+	}
+
+	for _, c := range candidates {
+		time.Sleep(b.recheckDelay)
+		b.checkRemoved(c.id, c.record, parseStory, parserWorks)
+	}
+}
+
+// checkRemoved re-fetches a single story and sends a removed event with the
+// archived title and company if it is gone. parserWorks reports whether a
+// listed story still parses.
+func (b *BaseService) checkRemoved(storyID string, record storage.Record, parseStory func(string) (*Story, error), parserWorks func() bool) {
+	now := time.Now().UTC()
+	record.RemovalCheckedAt = &now
+
+	_, err := parseStory(record.Link)
+	notFound := errors.Is(err, ErrStoryNotFound)
+	switch {
+	case err == nil:
+		record.RemovalErrors = 0
+		record.RemovalMisses = 0
+	case notFound && !parserWorks():
+		// The parser is broken, not the story
+		errorhandling.HandleError(errorhandling.NewError(errorhandling.ScrapingError,
+			"Failed to re-check story "+storyID+", listed stories do not parse either", err))
+	case notFound && record.RemovalMisses+1 < config.RemovalNotFoundChecks:
+		record.RemovalMisses++
+		record.RemovalErrors = 0
+	case !notFound && !IsStoryGone(err):
+		record.RemovalErrors++
+		errorhandling.HandleError(errorhandling.NewError(errorhandling.ScrapingError,
+			fmt.Sprintf("Failed to re-check story %s (%d/%d)", storyID, record.RemovalErrors, config.RemovalMaxErrors), err))
+	default:
+		title := record.Title
+		if title == "" {
+			title = storyID
+		}
+		event := &Event{
+			Kind: StoryRemoved,
+			Story: &Story{
+				ID:          storyID,
+				Source:      b.Source,
+				PublishedAt: record.PublishedAt,
+				ScrapedAt:   now,
+				Title:       title,
+				Company:     record.Company,
+				Tag:         record.Tag,
+				Link:        record.Link,
+			},
+			Detail: "This story is no longer available: " + err.Error(),
 		}
+		if err := b.NotifyEvent(event); err != nil {
+			errorhandling.HandleError(err)
+			return
+		}
+		record.RemovedAt = &now
+	}
 
-		contentEmbed := discordtexthook.Embed{
-			Title:       title,
-			Description: chunk,
-			Color:       b.EmbedColor,
+	if err := b.Storage.Save(storyID, record); err != nil {
+		errorhandling.HandleError(errorhandling.NewError(errorhandling.StorageError, "Failed to save removal check", err))
+	}
+}
+
+// describeChanges lists the changed fields and a diff of the description
+func describeChanges(record storage.Record, story *Story) string {
+	var changes strings.Builder
+	for _, field := range []struct {
+		name     string
+		old, new string
+	}{
+		{"Title", record.Title, story.Title},
+		{"Company", record.Company, story.Company},
+		{"Tag", record.Tag, story.Tag},
+	} {
+		if field.old != field.new {
+			changes.WriteString(field.name + ": " + field.old + " → " + field.new + "\n")
 		}
+	}
+
+	if diff := diffLines(record.Description, story.Description); diff != "" {
+		changes.WriteString("\n" + diff)
+	}
+	return strings.TrimSpace(changes.String())
+}
+
+// ContentHash returns a hash of the fields an edit can change
+func (s *Story) ContentHash() string {
+	sum := sha256.Sum256([]byte(s.Title + "\x00" + s.Company + "\x00" + s.Tag + "\x00" + s.Description))
+	return hex.EncodeToString(sum[:])
+}
 
-		if _, err := webhook.SendEmbed(contentEmbed); err != nil {
-			return errorhandling.NewError(errorhandling.DiscordError, "Failed to send description chunk to Discord", err)
+// StoryID returns the ID of a story link as used by the storage
+func (b *BaseService) StoryID(link string) string {
+	if b.IDFromLink != nil {
+		return b.IDFromLink(link)
+	}
+	return strings.TrimPrefix(link, b.BaseURL+"/story/")
+}
+
+// Notify sends a story to every configured notifier and returns the
+// messages it was sent as by ThreadNotifiers, keyed by notifier name. It only
+// fails when no active notifier accepted the story, so a single broken sink
+// does not cause the story to be re-sent to the others on the next run, while
+// a story that only reached a passive sink (see PassiveNotifier) is retried.
+func (b *BaseService) Notify(story *Story) (map[string]string, error) {
+	// Validate required fields
+	if story.Company == "" {
+		return nil, errorhandling.NewError(errorhandling.ValidationError, "Cannot send story with empty company name", nil)
+	}
+	if story.Description == "" {
+		return nil, errorhandling.NewError(errorhandling.ValidationError, "Cannot send story with empty description", nil)
+	}
+
+	b.notifyMu.Lock()
+	defer b.notifyMu.Unlock()
+
+	return b.fanOut("story", func(n Notifier) (string, error) {
+		if tn, ok := n.(ThreadNotifier); ok {
+			return tn.SendThreaded(story)
 		}
+		return "", n.Send(story)
+	})
+}
 
-		chunkNumber++
+// NotifyEvent sends an event to every configured notifier, as a reply where
+// the event has the message of a ThreadNotifier. Like Notify it only fails
+// when no active notifier accepted the event.
+func (b *BaseService) NotifyEvent(event *Event) error {
+	b.notifyMu.Lock()
+	defer b.notifyMu.Unlock()
+
+	_, err := b.fanOut(string(event.Kind)+" event", func(n Notifier) (string, error) {
+		if tn, ok := n.(ThreadNotifier); ok && event.ReplyTo[n.Name()] != "" {
+			return "", tn.Reply(event.ReplyTo[n.Name()], event)
+		}
+		if en, ok := n.(EventNotifier); ok {
+			return "", en.SendEvent(event)
+		}
+		return "", n.Send(event.AsStory())
+	})
+	return err
+}
+
+// fanOut calls send for every notifier and returns the message references
+// send returned. It fails with the last error if no active notifier
+// succeeded. Passive notifiers only count when they are the only ones
+// configured.
+func (b *BaseService) fanOut(what string, send func(n Notifier) (string, error)) (map[string]string, error) {
+	var lastErr error
+	messages := make(map[string]string)
+	active, delivered, recorded := 0, 0, 0
+	for _, n := range b.Notifiers {
+		passive := isPassive(n)
+		if !passive {
+			active++
+		}
+
+		ref, err := send(n)
+		if err != nil {
+			lastErr = errorhandling.NewError(errorhandling.NotifierError, "Failed to send "+what+" via "+n.Name(), err)
+			errorhandling.HandleError(lastErr)
+			continue
+		}
+		if ref != "" {
+			messages[n.Name()] = ref
+		}
+
+		if passive {
+			recorded++
+		} else {
+			delivered++
+		}
 	}
 
+	if active == 0 {
+		delivered = recorded
+	}
+	if delivered == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return messages, nil
+}
+
+// CheckHealth checks every configured notifier
+func (b *BaseService) CheckHealth() error {
+	for _, n := range b.Notifiers {
+		if err := n.Health(); err != nil {
+			return errorhandling.NewError(errorhandling.NotifierError, "Notifier "+n.Name()+" is unhealthy", err)
+		}
+	}
 	return nil
 }
 
+// Close closes the storage
+func (b *BaseService) Close() error {
+	return b.Storage.Close()
+}
+
 // HasStory checks if a story exists in storage
-func (b *BaseService) HasStory(storyID string) bool {
-	return b.Storage.HasStory(storyID)
+func (b *BaseService) HasStory(storyID string) (bool, error) {
+	exists, err := b.Storage.Has(storyID)
+	if err != nil {
+		return false, errorhandling.NewError(errorhandling.StorageError, "Failed to look up story "+storyID, err)
+	}
+	return exists, nil
 }
 
 // AddStory adds a story to storage
 func (b *BaseService) AddStory(storyID string) error {
-	return b.Storage.AddStory(storyID)
+	return b.Storage.Add(storyID)
 }
 
-// truncateString truncates a string to the specified maximum length
-func truncateString(s string, maxLength int) string {
-	if len(s) <= maxLength {
-		return s
+// SaveStory stores a fetched story with its content hash. messages are the
+// ones returned by Notify and mark the story as sent, nil when it was not
+// delivered. The comments of the first fetch are recorded as seen, later ones
+// are left to CheckComments.
+func (b *BaseService) SaveStory(storyID string, story *Story, messages map[string]string) error {
+	// A record that cannot be read must not be replaced by a new one
+	record, _, err := b.Storage.Get(storyID)
+	if err != nil {
+		return err
+	}
+	if record.Hash == "" {
+		for i := range story.Comments {
+			record.Comments = append(record.Comments, story.Comments[i].record())
+		}
+	}
+	record.Link = story.Link
+	record.Author = story.Author
+	record.ScrapedAt = story.ScrapedAt
+	if messages != nil && record.DeliveredAt == nil {
+		now := time.Now().UTC()
+		record.DeliveredAt = &now
+	}
+	if len(messages) > 0 {
+		// Copied, record.Messages may be shared with the store
+		merged := maps.Clone(record.Messages)
+		if merged == nil {
+			merged = make(map[string]string, len(messages))
+		}
+		maps.Copy(merged, messages)
+		record.Messages = merged
+	}
+	if !story.PublishedAt.IsZero() {
+		record.PublishedAt = story.PublishedAt
 	}
-	return s[:maxLength-3] + "..."
+	record.Hash = story.ContentHash()
+	record.Title = story.Title
+	record.Company = story.Company
+	record.Tag = story.Tag
+	record.Description = story.Description
+	return b.Storage.Save(storyID, record)
 }
@@ -0,0 +1,206 @@
+package base
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/config"
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/storage"
+)
+
+const listedLink = "https://example.com/story/listed"
+
+func TestCheckRemovedStories(t *testing.T) {
+	runs := config.RemovalNotFoundChecks + config.RemovalMaxErrors
+
+	tests := []struct {
+		name string
+		err  error
+		// listErr is returned for the story still on the list page
+		listErr error
+		// wantRemovedAt is the check that finds the story removed, 0 if none
+		wantRemovedAt int
+		wantChecks    int
+	}{
+		{"parses", nil, nil, 0, runs},
+		{"404", &StatusError{Code: http.StatusNotFound}, nil, 1, 1},
+		{"410", &StatusError{Code: http.StatusGone}, nil, 1, 1},
+		{"not found", ErrStoryNotFound, nil, config.RemovalNotFoundChecks, config.RemovalNotFoundChecks},
+		{"not found with a broken parser", ErrStoryNotFound, ErrStoryNotFound, 0, runs},
+		{"other error", &StatusError{Code: http.StatusBadGateway}, nil, 0, config.RemovalMaxErrors},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := storage.NewMemoryStore()
+			notifier := &fakeNotifier{name: "discord"}
+			b := NewBaseService(store, "https://example.com", []Notifier{notifier})
+			b.recheckDelay = 0
+
+			posted := time.Now().UTC().Add(-time.Hour)
+			if err := store.Save("1", storage.Record{Title: "Title", Link: "https://example.com/story/1", PublishedAt: posted}); err != nil {
+				t.Fatal(err)
+			}
+
+			checks := 0
+			parse := func(link string) (*Story, error) {
+				if link == listedLink {
+					return &Story{}, tt.listErr
+				}
+				checks++
+				return &Story{}, tt.err
+			}
+
+			removedAt := 0
+			for run := 1; run <= runs; run++ {
+				b.lastRemovalCheck = time.Time{}
+				b.CheckRemovedStories([]string{listedLink}, parse)
+
+				record, _, err := store.Get("1")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if record.RemovedAt != nil && removedAt == 0 {
+					removedAt = run
+				}
+			}
+
+			if removedAt != tt.wantRemovedAt {
+				t.Errorf("removed at check %d, want %d", removedAt, tt.wantRemovedAt)
+			}
+			if checks != tt.wantChecks {
+				t.Errorf("story checked %d times, want %d", checks, tt.wantChecks)
+			}
+			wantSent := 0
+			if tt.wantRemovedAt > 0 {
+				wantSent = 1
+			}
+			if notifier.sent != wantSent {
+				t.Errorf("sent %d notifications, want %d", notifier.sent, wantSent)
+			}
+		})
+	}
+}
+
+func TestCheckRemovedStoriesSkipsListedAndOldStories(t *testing.T) {
+	store := storage.NewMemoryStore()
+	b := NewBaseService(store, "https://example.com", nil)
+	b.recheckDelay = 0
+
+	now := time.Now().UTC()
+	records := map[string]storage.Record{
+		"listed": {Link: listedLink, PublishedAt: now},
+		"recent": {Link: "https://example.com/story/recent", PublishedAt: now.Add(-config.RemovalWatchWindow + time.Hour)},
+		"old":    {Link: "https://example.com/story/old", PublishedAt: now.Add(-config.RemovalWatchWindow - time.Hour)},
+		// Without a publish date the first sighting counts
+		"seen long ago": {Link: "https://example.com/story/seen", FirstSeen: now.Add(-config.RemovalWatchWindow - time.Hour)},
+	}
+	for id, record := range records {
+		if err := store.Save(id, record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var checked []string
+	b.CheckRemovedStories([]string{listedLink}, func(link string) (*Story, error) {
+		checked = append(checked, link)
+		return &Story{}, nil
+	})
+
+	if len(checked) != 1 || checked[0] != "https://example.com/story/recent" {
+		t.Errorf("checked %v, want only the recent story that left the list", checked)
+	}
+}
+
+func TestCheckRemovedStoriesNeedsAListedStory(t *testing.T) {
+	b := NewBaseService(storage.NewMemoryStore(), "https://example.com", nil)
+	b.recheckDelay = 0
+	if err := b.Storage.Save("1", storage.Record{Link: "https://example.com/story/1", PublishedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	// An empty list page is more likely broken than a mass removal
+	b.CheckRemovedStories(nil, func(link string) (*Story, error) {
+		return nil, errors.New("unexpected fetch")
+	})
+	if record, _, _ := b.Storage.Get("1"); record.RemovalCheckedAt != nil {
+		t.Error("story checked without a list page")
+	}
+}
+
+// eventRecorder records the events it receives
+type eventRecorder struct {
+	fakeNotifier
+	events []*Event
+}
+
+func (e *eventRecorder) SendEvent(event *Event) error {
+	e.events = append(e.events, event)
+	return nil
+}
+
+func TestRecheckStories(t *testing.T) {
+	link := "https://example.com/story/1"
+	sent := &Story{Link: link, Title: "Old title", Company: "Company", Tag: "Tag", Description: "First\nSecond"}
+
+	tests := []struct {
+		name string
+		// seenOnly marks the story as seen instead of saving it as sent
+		seenOnly    bool
+		story       Story
+		wantDetails []string
+	}{
+		{"unchanged", false, *sent, nil},
+		{"edited", false, Story{Link: link, Title: "New title", Company: "Company", Tag: "Tag", Description: "First\nThird"},
+			[]string{"Title: Old title → New title", "- Second", "+ Third"}},
+		{"blank lines only", false, Story{Link: link, Title: "Old title", Company: "Company", Tag: "Tag", Description: "First\n\nSecond"}, nil},
+		{"seen only", true, Story{Link: link, Title: "New title", Company: "Company", Tag: "Tag", Description: "First"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := &eventRecorder{fakeNotifier: fakeNotifier{name: "discord"}}
+			b := NewBaseService(storage.NewMemoryStore(), "https://example.com", []Notifier{recorder})
+			b.recheckDelay = 0
+
+			if tt.seenOnly {
+				if err := b.Storage.Add("1"); err != nil {
+					t.Fatal(err)
+				}
+			} else if err := b.SaveStory("1", sent, map[string]string{}); err != nil {
+				t.Fatal(err)
+			}
+
+			b.RecheckStories([]string{link, "https://example.com/story/unknown"}, func(string) (*Story, error) {
+				story := tt.story
+				return &story, nil
+			})
+
+			if tt.wantDetails == nil && len(recorder.events) != 0 {
+				t.Errorf("sent %d events, want none", len(recorder.events))
+			}
+			if tt.wantDetails != nil {
+				if len(recorder.events) != 1 || recorder.events[0].Kind != StoryUpdated {
+					t.Fatalf("events = %+v, want one update", recorder.events)
+				}
+				for _, want := range tt.wantDetails {
+					if !strings.Contains(recorder.events[0].Detail, want) {
+						t.Errorf("detail = %q, want it to contain %q", recorder.events[0].Detail, want)
+					}
+				}
+			}
+
+			// The fetched content is recorded either way
+			record, _, err := b.Storage.Get("1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if record.Hash != tt.story.ContentHash() || record.Title != tt.story.Title {
+				t.Errorf("record = %+v, want the re-fetched story", record)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package base
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/config"
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/errorhandling"
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/storage"
+)
+
+// TrackVotes adds the vote count of a fetched story to its time series and
+// sends a trending event when the story gains votes faster than
+// TrendingVelocity within TrendingWindow of being posted
+func (b *BaseService) TrackVotes(storyID string, story *Story) {
+	record, exists, err := b.Storage.Get(storyID)
+	if err != nil {
+		errorhandling.HandleError(errorhandling.NewError(errorhandling.StorageError, "Failed to read votes", err))
+		return
+	}
+	// Sources that do not parse votes always report 0
+	if !exists || (story.Votes == 0 && len(record.Votes) == 0) {
+		return
+	}
+
+	now := time.Now().UTC()
+	record.Votes = append(record.Votes, storage.VoteSample{At: now, Count: story.Votes})
+	if len(record.Votes) > config.VoteSamplesLimit {
+		record.Votes = record.Votes[len(record.Votes)-config.VoteSamplesLimit:]
+	}
+
+	if detail, trending := b.trending(record, now); trending {
+		event := &Event{
+			Kind:   StoryTrending,
+			Story:  story,
+			Detail: detail,
+		}
+		if err := b.NotifyEvent(event); err != nil {
+			errorhandling.HandleError(err)
+		} else {
+			record.TrendingAt = &now
+		}
+	}
+
+	if err := b.Storage.Save(storyID, record); err != nil {
+		errorhandling.HandleError(errorhandling.NewError(errorhandling.StorageError, "Failed to save votes", err))
+	}
+}
+
+// trending compares the latest vote count with the last sample taken at
+// least config.TrendingMinSpan earlier and reports whether the velocity
+// crosses the threshold
+func (b *BaseService) trending(record storage.Record, now time.Time) (string, bool) {
+	if b.TrendingVelocity <= 0 || record.TrendingAt != nil {
+		return "", false
+	}
+
+	posted := record.PublishedAt
+	if posted.IsZero() {
+		posted = record.FirstSeen
+	}
+	if now.Sub(posted) > b.TrendingWindow {
+		return "", false
+	}
+
+	latest := record.Votes[len(record.Votes)-1]
+	for i := len(record.Votes) - 2; i >= 0; i-- {
+		previous := record.Votes[i]
+		span := latest.At.Sub(previous.At)
+		if span < config.TrendingMinSpan {
+			continue
+		}
+
+		velocity := float64(latest.Count-previous.Count) / span.Hours()
+		if velocity < b.TrendingVelocity {
+			return "", false
+		}
+		return fmt.Sprintf("%d votes, +%d in the last %s (%.1f per hour)",
+			latest.Count, latest.Count-previous.Count, span.Round(time.Minute), velocity), true
+	}
+	return "", false
+}
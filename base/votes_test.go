@@ -0,0 +1,90 @@
+package base
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/storage"
+)
+
+func TestTrending(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	sample := func(ago time.Duration, count int) storage.VoteSample {
+		return storage.VoteSample{At: now.Add(-ago), Count: count}
+	}
+	sent := now.Add(-time.Minute)
+
+	tests := []struct {
+		name       string
+		velocity   float64
+		record     storage.Record
+		want       bool
+		wantDetail string
+	}{
+		{"fast", 10, storage.Record{PublishedAt: now.Add(-2 * time.Hour), Votes: []storage.VoteSample{sample(time.Hour, 0), sample(0, 20)}},
+			true, "20 votes, +20 in the last 1h0m0s (20.0 per hour)"},
+		{"at the threshold", 10, storage.Record{PublishedAt: now.Add(-2 * time.Hour), Votes: []storage.VoteSample{sample(time.Hour, 0), sample(0, 10)}},
+			true, "10 votes, +10 in the last 1h0m0s (10.0 per hour)"},
+		{"slow", 10, storage.Record{PublishedAt: now.Add(-2 * time.Hour), Votes: []storage.VoteSample{sample(time.Hour, 0), sample(0, 5)}},
+			false, ""},
+		// A burst between two close samples is not measured
+		{"samples closer than the minimum span are skipped", 10, storage.Record{PublishedAt: now.Add(-2 * time.Hour),
+			Votes: []storage.VoteSample{sample(time.Hour, 0), sample(5*time.Minute, 4), sample(0, 6)}}, false, ""},
+		{"latest sample old enough is the baseline", 10, storage.Record{PublishedAt: now.Add(-3 * time.Hour),
+			Votes: []storage.VoteSample{sample(2*time.Hour, 0), sample(30*time.Minute, 0), sample(0, 10)}},
+			true, "10 votes, +10 in the last 30m0s (20.0 per hour)"},
+		{"no sample old enough", 10, storage.Record{PublishedAt: now.Add(-time.Hour), Votes: []storage.VoteSample{sample(10*time.Minute, 0), sample(0, 20)}},
+			false, ""},
+		{"posted before the window", 10, storage.Record{PublishedAt: now.Add(-25 * time.Hour), Votes: []storage.VoteSample{sample(time.Hour, 0), sample(0, 20)}},
+			false, ""},
+		{"first seen before the window", 10, storage.Record{FirstSeen: now.Add(-25 * time.Hour), Votes: []storage.VoteSample{sample(time.Hour, 0), sample(0, 20)}},
+			false, ""},
+		{"already sent", 10, storage.Record{PublishedAt: now.Add(-2 * time.Hour), TrendingAt: &sent, Votes: []storage.VoteSample{sample(time.Hour, 0), sample(0, 20)}},
+			false, ""},
+		{"disabled", 0, storage.Record{PublishedAt: now.Add(-2 * time.Hour), Votes: []storage.VoteSample{sample(time.Hour, 0), sample(0, 20)}},
+			false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBaseService(storage.NewMemoryStore(), "https://example.com", nil)
+			b.TrendingVelocity = tt.velocity
+			b.TrendingWindow = 24 * time.Hour
+
+			detail, trending := b.trending(tt.record, now)
+			if trending != tt.want || detail != tt.wantDetail {
+				t.Errorf("trending = %q, %v, want %q, %v", detail, trending, tt.wantDetail, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrackVotesSendsTrendingOnce(t *testing.T) {
+	notifier := &fakeNotifier{name: "discord"}
+	b := NewBaseService(storage.NewMemoryStore(), "https://example.com", []Notifier{notifier})
+	b.TrendingVelocity = 10
+	b.TrendingWindow = 24 * time.Hour
+
+	now := time.Now().UTC()
+	if err := b.Storage.Save("1", storage.Record{
+		PublishedAt: now.Add(-2 * time.Hour),
+		Votes:       []storage.VoteSample{{At: now.Add(-time.Hour), Count: 0}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, votes := range []int{20, 40, 60} {
+		b.TrackVotes("1", &Story{Title: "Title", Votes: votes})
+	}
+
+	if notifier.sent != 1 {
+		t.Errorf("sent %d trending events, want 1", notifier.sent)
+	}
+	record, _, err := b.Storage.Get("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.TrendingAt == nil || len(record.Votes) != 4 {
+		t.Errorf("record = %+v, want the trending date and every sample", record)
+	}
+}
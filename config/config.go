@@ -18,6 +18,35 @@ const (
 	StorageDir      = "storage"
 	MulaStorageFile = "mula_sent_stories.json"
 	OakStorageFile  = "oak_sent_stories.json"
+	FeedSize        = 50
+	BackfillPages   = 10
+	BackfillDelay   = 2 * time.Second
+	RecheckInterval = 30 * time.Minute
+	RecheckLimit    = 10
+	RecheckDelay    = 2 * time.Second
+	// RemovalWatchWindow is how long after posting a story is checked for
+	// removal once it left the list page
+	RemovalWatchWindow   = 7 * 24 * time.Hour
+	RemovalCheckInterval = time.Hour
+	// RemovalMaxErrors is the number of failed removal checks in a row
+	// after which a story is no longer checked
+	RemovalMaxErrors = 5
+	// RemovalNotFoundChecks is the number of checks in a row that load the
+	// page without the story, while a listed story still parses, after
+	// which the story counts as removed
+	RemovalNotFoundChecks = 3
+	// CommentWatchWindow is the default of COMMENT_WATCH_WINDOW
+	CommentWatchWindow   = 48 * time.Hour
+	CommentCheckInterval = 10 * time.Minute
+	// TrendingWindow is the default of TRENDING_WINDOW
+	TrendingWindow = 24 * time.Hour
+	// TrendingMinSpan is the shortest span a vote velocity is measured over
+	TrendingMinSpan  = 15 * time.Minute
+	VoteSamplesLimit = 100
+	PruneInterval    = 24 * time.Hour
+	// PrunePages is the number of list pages whose stories the automatic
+	// prune keeps, and the default of the prune command
+	PrunePages = 3
 )
 
 type HTTPConfig struct {
@@ -3,12 +3,9 @@ package errorhandling
 import (
 	"fmt"
 	"log"
-	"os"
 	"runtime"
 	"sync"
 	"time"
-
-	discordtexthook "github.com/nahidhasan98/discord-text-hook"
 )
 
 type ErrorType int
@@ -21,6 +18,7 @@ const (
 	StorageError
 	ValidationError
 	ParseError
+	NotifierError
 )
 
 type AppError struct {
@@ -61,19 +59,23 @@ func formatErrorMessage(err error) string {
 	return msg
 }
 
-func sendToDiscord(msg string) error {
-	webhookID := os.Getenv("WEBHOOK_ID_ERROR")
-	webhookToken := os.Getenv("WEBHOOK_TOKEN_ERROR")
+// Reporter delivers formatted error reports
+type Reporter interface {
+	SendError(message string) error
+}
 
-	if webhookID == "" || webhookToken == "" {
-		return fmt.Errorf("discord webhook configuration missing")
-	}
+var reporter Reporter
 
-	formattedMsg := fmt.Sprintf("```md\n%s```", msg)
-	webhook := discordtexthook.NewDiscordTextHookService(webhookID, webhookToken)
+// SetReporter sets where HandleError sends error reports
+func SetReporter(r Reporter) {
+	reporter = r
+}
 
-	_, err := webhook.SendMessage(formattedMsg)
-	return err
+func sendReport(msg string) error {
+	if reporter == nil {
+		return fmt.Errorf("error reporter not configured")
+	}
+	return reporter.SendError(msg)
 }
 
 type ErrorTracker struct {
@@ -128,15 +130,15 @@ func HandleError(err error) {
 
 	log.Printf("ERROR: %v\n", err)
 
-	// Check if we should send this error to Discord
+	// Check if we should send this error report
 	if !tracker.shouldSendError(err) {
 		log.Printf("Skipping error notification (cooldown): %v\n", err)
 		return
 	}
 
 	msg := formatErrorMessage(err)
-	if discordErr := sendToDiscord(msg); discordErr != nil {
-		log.Printf("Failed to send error to Discord: %v\n", discordErr)
+	if reportErr := sendReport(msg); reportErr != nil {
+		log.Printf("Failed to send error report: %v\n", reportErr)
 	}
 
 	// Cleanup old errors periodically
@@ -0,0 +1,240 @@
+package feed
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/base"
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/config"
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/errorhandling"
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/storage"
+)
+
+// Item is a story published in the feed
+type Item struct {
+	Source string
+	ID     string
+	Story  base.Story
+	// Added is when the story was first seen
+	Added time.Time
+}
+
+// Feed publishes the most recent stories kept in the storage of every
+// source, so it survives restarts and includes stories only marked as seen
+type Feed struct {
+	sources map[string]*view
+	size    int
+	mu      sync.RWMutex
+}
+
+var shared = New(config.FeedSize)
+
+// Shared returns the feed served by ListenAndServe
+func Shared() *Feed {
+	return shared
+}
+
+// New creates a feed showing up to size stories
+func New(size int) *Feed {
+	return &Feed{
+		sources: make(map[string]*view),
+		size:    size,
+	}
+}
+
+// AddSource publishes the stories of a source kept in store. Stories must be
+// saved through the returned store, it keeps the feed of the source up to
+// date without listing the whole store on every request.
+func (f *Feed) AddSource(source string, store storage.Store) storage.Store {
+	name := strings.ToLower(source)
+	v := &view{Store: store, name: name, size: f.size}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.sources[name] = v
+	return v
+}
+
+// Items returns the most recent stories, newest first. An empty source
+// returns the stories of every source, an unknown one returns false.
+// Stories never fetched (no title yet) and removed stories are left out.
+func (f *Feed) Items(source string) ([]Item, bool) {
+	source = strings.ToLower(source)
+
+	f.mu.RLock()
+	var views []*view
+	for name, v := range f.sources {
+		if source == "" || name == source {
+			views = append(views, v)
+		}
+	}
+	f.mu.RUnlock()
+
+	if source != "" && len(views) == 0 {
+		return nil, false
+	}
+
+	var items []Item
+	for _, v := range views {
+		items = append(items, v.recent()...)
+	}
+	sortItems(items)
+	if len(items) > f.size {
+		items = items[:f.size]
+	}
+	return items, true
+}
+
+// view is the store of a source, keeping its most recent publishable
+// stories as they are saved
+type view struct {
+	storage.Store
+	name string
+	size int
+
+	mu    sync.Mutex
+	items []Item
+	// loaded is false until the items are read from the store, and again
+	// once a removal may let an older story back in
+	loaded bool
+}
+
+// Save saves the record and updates the feed items
+func (v *view) Save(id string, record storage.Record) error {
+	if err := v.Store.Save(id, record); err != nil {
+		return err
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if !v.loaded {
+		return nil
+	}
+	full := len(v.items) >= v.size
+	removed := v.remove(id)
+	if publishable(record) {
+		v.items = append(v.items, newItem(v.name, id, record))
+		sortItems(v.items)
+		if len(v.items) > v.size {
+			v.items = v.items[:v.size]
+		}
+	}
+	// A story moved out of a full feed, an older one may belong in its place
+	if removed && full && (len(v.items) < v.size || v.items[len(v.items)-1].ID == id) {
+		v.loaded = false
+	}
+	return nil
+}
+
+// Delete deletes the story and drops it from the feed items
+func (v *view) Delete(id string) error {
+	if err := v.Store.Delete(id); err != nil {
+		return err
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.loaded && len(v.items) >= v.size && v.remove(id) {
+		v.loaded = false
+	}
+	return nil
+}
+
+// recent returns the feed items, reading them from the store if needed
+func (v *view) recent() []Item {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if !v.loaded {
+		records, err := v.Store.List()
+		if err != nil {
+			errorhandling.HandleError(errorhandling.NewError(errorhandling.StorageError, "Failed to list "+v.name+" stories for the feed", err))
+			return nil
+		}
+
+		v.items = v.items[:0]
+		for id, record := range records {
+			if publishable(record) {
+				v.items = append(v.items, newItem(v.name, id, record))
+			}
+		}
+		sortItems(v.items)
+		if len(v.items) > v.size {
+			v.items = v.items[:v.size]
+		}
+		v.loaded = true
+	}
+	return append([]Item(nil), v.items...)
+}
+
+// remove drops a story from the items and reports whether it was there
+func (v *view) remove(id string) bool {
+	for i, item := range v.items {
+		if item.ID == id {
+			v.items = append(v.items[:i], v.items[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func publishable(record storage.Record) bool {
+	return record.Title != "" && record.Link != "" && record.RemovedAt == nil
+}
+
+// sortItems sorts items newest first
+func sortItems(items []Item) {
+	sort.Slice(items, func(i, j int) bool {
+		if !items[i].Published().Equal(items[j].Published()) {
+			return items[i].Published().After(items[j].Published())
+		}
+		return guid(items[i]) < guid(items[j])
+	})
+}
+
+func newItem(source, id string, record storage.Record) Item {
+	return Item{
+		Source: source,
+		ID:     id,
+		Story: base.Story{
+			ID:          id,
+			Source:      source,
+			Title:       record.Title,
+			Company:     record.Company,
+			Tag:         record.Tag,
+			Description: record.Description,
+			Link:        record.Link,
+			Author:      record.Author,
+			PublishedAt: record.PublishedAt,
+			ScrapedAt:   record.ScrapedAt,
+		},
+		Added: record.FirstSeen,
+	}
+}
+
+// Published returns the publish date of the story, or when it was first
+// seen if the site shows none
+func (i Item) Published() time.Time {
+	if !i.Story.PublishedAt.IsZero() {
+		return i.Story.PublishedAt
+	}
+	return i.Added
+}
+
+// Updated returns when the story was last fetched
+func (i Item) Updated() time.Time {
+	if !i.Story.ScrapedAt.IsZero() {
+		return i.Story.ScrapedAt
+	}
+	return i.Added
+}
+
+// guid returns a stable identifier for a story
+func guid(item Item) string {
+	return "urn:deshimula-notifier:" + item.Source + ":" + item.ID
+}
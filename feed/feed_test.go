@@ -0,0 +1,128 @@
+package feed
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/storage"
+)
+
+func TestItemsFromStorage(t *testing.T) {
+	store := storage.NewMemoryStore()
+	now := time.Now().UTC()
+	removed := now
+
+	records := map[string]storage.Record{
+		"old":     {Title: "Old", Link: "https://example.com/story/old", PublishedAt: now.Add(-2 * time.Hour)},
+		"new":     {Title: "New", Link: "https://example.com/story/new", PublishedAt: now.Add(-time.Hour)},
+		"seen":    {},
+		"removed": {Title: "Removed", Link: "https://example.com/story/removed", RemovedAt: &removed},
+	}
+	for id, record := range records {
+		if err := store.Save(id, record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f := New(10)
+	f.AddSource("Mula", store)
+
+	items, exists := f.Items("MULA")
+	if !exists || len(items) != 2 || items[0].ID != "new" || items[1].ID != "old" {
+		t.Fatalf("Items = %+v, %v, want new and old", items, exists)
+	}
+	if items, exists := f.Items("oak"); exists || len(items) != 0 {
+		t.Error("unknown source returned items")
+	}
+
+	rec := httptest.NewRecorder()
+	f.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/atom.xml", nil))
+	body := rec.Body.String()
+	if !strings.Contains(body, "urn:deshimula-notifier:mula:new") || strings.Contains(body, "Removed") {
+		t.Errorf("unexpected atom feed:\n%s", body)
+	}
+
+	rec = httptest.NewRecorder()
+	f.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/Mula/rss.xml", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "https://example.com/story/new") {
+		t.Errorf("source feed = %d, want the mula stories", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	f.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/oak/atom.xml", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown source feed = %d, want 404", rec.Code)
+	}
+}
+
+// listCounter counts how often the feed lists the whole store
+type listCounter struct {
+	storage.Store
+	lists int
+}
+
+func (s *listCounter) List() (map[string]storage.Record, error) {
+	s.lists++
+	return s.Store.List()
+}
+
+func TestItemsFollowSavesAndDeletes(t *testing.T) {
+	counter := &listCounter{Store: storage.NewMemoryStore()}
+	now := time.Now().UTC()
+	story := func(hours int) storage.Record {
+		return storage.Record{
+			Title:       "Story",
+			Link:        "https://example.com/story",
+			PublishedAt: now.Add(time.Duration(hours) * time.Hour),
+		}
+	}
+
+	f := New(2)
+	store := f.AddSource("mula", counter)
+	for id, hours := range map[string]int{"a": -1, "b": -2, "c": -3} {
+		if err := store.Save(id, story(hours)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ids := func() string {
+		t.Helper()
+		items, _ := f.Items("")
+		var ids []string
+		for _, item := range items {
+			ids = append(ids, item.ID)
+		}
+		return strings.Join(ids, " ")
+	}
+
+	steps := []struct {
+		name      string
+		change    func() error
+		want      string
+		wantLists int
+	}{
+		{"first request lists the store", func() error { return nil }, "a b", 1},
+		{"newer story", func() error { return store.Save("d", story(0)) }, "d a", 1},
+		{"older story", func() error { return store.Save("e", story(-4)) }, "d a", 1},
+		{"edited story", func() error { return store.Save("a", story(1)) }, "a d", 1},
+		{"marked as seen", func() error { return store.Add("f") }, "a d", 1},
+		{"removed story", func() error {
+			record := story(1)
+			record.RemovedAt = &now
+			return store.Save("a", record)
+		}, "d b", 2},
+		{"deleted story", func() error { return store.Delete("d") }, "b c", 3},
+		{"deleted story outside the feed", func() error { return store.Delete("e") }, "b c", 3},
+	}
+
+	for _, step := range steps {
+		if err := step.change(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if got := ids(); got != step.want || counter.lists != step.wantLists {
+			t.Errorf("%s: items = %q after %d lists, want %q after %d", step.name, got, counter.lists, step.want, step.wantLists)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package feed
+
+import (
+	"encoding/xml"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr,omitempty"`
+	Body string `xml:",chardata"`
+}
+
+type atomEntry struct {
+	Title     string     `xml:"title"`
+	ID        string     `xml:"id"`
+	Link      atomLink   `xml:"link"`
+	Updated   string     `xml:"updated"`
+	Published string     `xml:"published,omitempty"`
+	Author    *atomName  `xml:"author,omitempty"`
+	Category  []atomTerm `xml:"category,omitempty"`
+	Summary   string     `xml:"summary,omitempty"`
+	Content   atomText   `xml:"content"`
+}
+
+type atomName struct {
+	Name string `xml:"name"`
+}
+
+type atomTerm struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Link    atomLink    `xml:"link"`
+	Updated string      `xml:"updated"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type rssGUID struct {
+	IsPermaLink string `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        rssGUID  `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+	Category    []string `xml:"category,omitempty"`
+	Description string   `xml:"description"`
+}
+
+type rssChannel struct {
+	Title       string    `xml:"title"`
+	Link        string    `xml:"link"`
+	Description string    `xml:"description"`
+	Items       []rssItem `xml:"item"`
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+// Handler serves the combined feeds at /atom.xml and /rss.xml and the
+// per-source feeds at /{source}/atom.xml and /{source}/rss.xml
+func (f *Feed) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /atom.xml", func(w http.ResponseWriter, r *http.Request) {
+		f.serveAtom(w, r, "")
+	})
+	mux.HandleFunc("GET /rss.xml", func(w http.ResponseWriter, r *http.Request) {
+		f.serveRSS(w, r, "")
+	})
+	mux.HandleFunc("GET /{source}/atom.xml", func(w http.ResponseWriter, r *http.Request) {
+		f.serveAtom(w, r, strings.ToLower(r.PathValue("source")))
+	})
+	mux.HandleFunc("GET /{source}/rss.xml", func(w http.ResponseWriter, r *http.Request) {
+		f.serveRSS(w, r, strings.ToLower(r.PathValue("source")))
+	})
+	return mux
+}
+
+// ListenAndServe serves the shared feed on addr
+func ListenAndServe(addr string) error {
+	log.Println("Serving feeds on", addr)
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           shared.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return server.ListenAndServe()
+}
+
+func (f *Feed) serveAtom(w http.ResponseWriter, r *http.Request, source string) {
+	items, exists := f.Items(source)
+	if !exists {
+		http.NotFound(w, r)
+		return
+	}
+
+	feed := atomFeed{
+		Title:   feedTitle(source),
+		ID:      "urn:deshimula-notifier:" + feedName(source),
+		Link:    atomLink{Href: requestURL(r), Rel: "self"},
+		Updated: time.Now().UTC().Format(time.RFC3339),
+	}
+	var updated time.Time
+	for _, item := range items {
+		if item.Updated().After(updated) {
+			updated = item.Updated()
+		}
+	}
+	if !updated.IsZero() {
+		feed.Updated = updated.Format(time.RFC3339)
+	}
+
+	for _, item := range items {
+		entry := atomEntry{
+			Title:     item.Story.Title,
+			ID:        guid(item),
+			Link:      atomLink{Href: item.Story.Link},
+			Updated:   item.Updated().Format(time.RFC3339),
+			Published: item.Published().Format(time.RFC3339),
+			Summary:   item.Story.Company,
+			Content:   atomText{Type: "text", Body: item.Story.Description},
+		}
+		if item.Story.Author != "" {
+			entry.Author = &atomName{Name: item.Story.Author}
+		}
+		for _, term := range categories(item) {
+			entry.Category = append(entry.Category, atomTerm{Term: term})
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	writeXML(w, "application/atom+xml; charset=utf-8", feed)
+}
+
+func (f *Feed) serveRSS(w http.ResponseWriter, r *http.Request, source string) {
+	items, exists := f.Items(source)
+	if !exists {
+		http.NotFound(w, r)
+		return
+	}
+
+	feed := rssFeed{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:       feedTitle(source),
+			Link:        requestURL(r),
+			Description: "Most recent stories seen by the notifier",
+		},
+	}
+
+	for _, item := range items {
+		feed.Channel.Items = append(feed.Channel.Items, rssItem{
+			Title:       item.Story.Title,
+			Link:        item.Story.Link,
+			GUID:        rssGUID{IsPermaLink: "false", Value: guid(item)},
+			PubDate:     item.Published().Format(time.RFC1123Z),
+			Category:    categories(item),
+			Description: item.Story.Description,
+		})
+	}
+
+	writeXML(w, "application/rss+xml; charset=utf-8", feed)
+}
+
+func writeXML(w http.ResponseWriter, contentType string, v interface{}) {
+	w.Header().Set("Content-Type", contentType)
+	w.Write([]byte(xml.Header))
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		log.Println("Failed to encode feed:", err)
+	}
+}
+
+func categories(item Item) []string {
+	var terms []string
+	for _, term := range []string{item.Source, item.Story.Company, item.Story.Tag} {
+		if term != "" {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+func feedName(source string) string {
+	if source == "" {
+		return "all"
+	}
+	return source
+}
+
+func feedTitle(source string) string {
+	if source == "" {
+		return "Deshimula Notifier: all stories"
+	}
+	return "Deshimula Notifier: " + source + " stories"
+}
+
+func requestURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https") {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + r.URL.Path
+}
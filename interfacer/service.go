@@ -1,5 +1,9 @@
 package interfacer
 
 type Service interface {
+	Name() string
 	FetchAndProcessStories() error
+	CheckHealth() error
+	// Close releases the storage of the service
+	Close() error
 }
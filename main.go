@@ -1,68 +1,140 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"os"
+	"os/signal"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/nahidhasan98/deshimula-notifier-unofficial/config"
 	"github.com/nahidhasan98/deshimula-notifier-unofficial/errorhandling"
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/feed"
 	"github.com/nahidhasan98/deshimula-notifier-unofficial/interfacer"
-	"github.com/nahidhasan98/deshimula-notifier-unofficial/mula"
-	"github.com/nahidhasan98/deshimula-notifier-unofficial/oak"
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/notifier"
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/registry"
+	_ "github.com/nahidhasan98/deshimula-notifier-unofficial/sources"
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/storage"
 )
 
-func checkPeriodically(service interfacer.Service) {
+// checkPeriodically checks a service until ctx is done, a running check is
+// finished first
+func checkPeriodically(ctx context.Context, service interfacer.Service) {
 	ticker := time.NewTicker(config.Interval)
 	defer ticker.Stop()
 
-	log.Println("Starting periodic story check every minute...")
+	log.Printf("Starting periodic %s story check every minute...\n", service.Name())
 
-	for range ticker.C {
-		if err := service.FetchAndProcessStories(); err != nil {
-			errorhandling.HandleError(err)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := service.FetchAndProcessStories(); err != nil {
+				errorhandling.HandleError(err)
+			}
 		}
 	}
 }
 
+// lockStorage takes the storage lock for the rest of the process, so the
+// service and the maintenance commands never write the same stores at once
+func lockStorage() *storage.DirLock {
+	lock, err := storage.Lock(config.StorageDir)
+	if errors.Is(err, storage.ErrLocked) {
+		log.Fatalf("The %s directory is in use by another process, stop the running service first", config.StorageDir)
+	}
+	if err != nil {
+		log.Fatalf("Failed to lock %s: %v", config.StorageDir, err)
+	}
+	return lock
+}
+
 func main() {
 	if err := godotenv.Load(); err != nil {
 		log.Fatalf("Failed to load .env file: %v", err)
 	}
 
-	mulaService, err := mula.New()
+	reporter, err := notifier.ErrorReporterFromEnv()
 	if err != nil {
-		log.Fatalf("Failed to initialize mula client: %v", err)
+		log.Fatalf("Failed to initialize error reporting: %v", err)
 	}
+	errorhandling.SetReporter(reporter)
 
-	oakService, err := oak.New()
-	if err != nil {
-		log.Fatalf("Failed to initialize oak client: %v", err)
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "backfill":
+			runBackfill(os.Args[2:])
+			return
+		case "prune":
+			runPrune(os.Args[2:])
+			return
+		default:
+			log.Fatalf("Unknown command: %s", os.Args[1])
+		}
 	}
 
-	var wg sync.WaitGroup
-	wg.Add(2)
+	lock := lockStorage()
+	defer lock.Unlock()
 
-	go func() {
-		defer wg.Done()
-		if err := mulaService.FetchAndProcessStories(); err != nil {
-			errorhandling.HandleError(err)
-		}
-	}()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	services, err := registry.Enabled()
+	if err != nil {
+		log.Fatalf("Failed to initialize sources: %v", err)
+	}
 
-	go func() {
-		defer wg.Done()
-		if err := oakService.FetchAndProcessStories(); err != nil {
+	for _, service := range services {
+		if err := service.CheckHealth(); err != nil {
 			errorhandling.HandleError(err)
 		}
-	}()
+	}
+
+	if addr := os.Getenv("FEED_ADDR"); addr != "" {
+		go func() {
+			if err := feed.ListenAndServe(addr); err != nil {
+				errorhandling.HandleError(errorhandling.NewError(errorhandling.ConfigError, "Feed server stopped", err))
+			}
+		}()
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(len(services))
+
+	for _, service := range services {
+		go func(service interfacer.Service) {
+			defer wg.Done()
+			if err := service.FetchAndProcessStories(); err != nil {
+				errorhandling.HandleError(err)
+			}
+		}(service)
+	}
 
 	wg.Wait()
 
-	go checkPeriodically(mulaService)
-	go checkPeriodically(oakService)
+	wg.Add(len(services))
+	for _, service := range services {
+		go func(service interfacer.Service) {
+			defer wg.Done()
+			checkPeriodically(ctx, service)
+		}(service)
+	}
+
+	<-ctx.Done()
+	// A second signal exits right away
+	stop()
+	log.Println("Shutting down after the running checks...")
+	wg.Wait()
 
-	// Keep the main goroutine alive
-	select {}
+	// Closing compacts the JSON write-ahead logs and releases the databases
+	for _, service := range services {
+		if err := service.Close(); err != nil {
+			errorhandling.HandleError(errorhandling.NewError(errorhandling.StorageError, "Failed to close "+service.Name()+" storage", err))
+		}
+	}
 }
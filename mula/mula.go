@@ -2,66 +2,44 @@ package mula
 
 import (
 	"errors"
-	"fmt"
 	"log"
 	"net/http"
-	"os"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/nahidhasan98/deshimula-notifier-unofficial/base"
 	"github.com/nahidhasan98/deshimula-notifier-unofficial/config"
 	"github.com/nahidhasan98/deshimula-notifier-unofficial/errorhandling"
-	"github.com/nahidhasan98/deshimula-notifier-unofficial/interfacer"
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/registry"
 )
 
-type Mula struct {
-	*base.BaseService
-}
-
-func New() (interfacer.Service, error) {
-	if os.Getenv("WEBHOOK_ID_MULA") == "" || os.Getenv("WEBHOOK_TOKEN_MULA") == "" ||
-		os.Getenv("WEBHOOK_ID_ERROR") == "" || os.Getenv("WEBHOOK_TOKEN_ERROR") == "" {
-		return nil, errorhandling.NewError(errorhandling.ConfigError, "Missing webhook configuration", nil)
-	}
-
-	webhookID := os.Getenv("WEBHOOK_ID_MULA")
-	webhookToken := os.Getenv("WEBHOOK_TOKEN_MULA")
-	if os.Getenv("MODE") == "DEVELOPMENT" {
-		webhookID = os.Getenv("WEBHOOK_ID_ERROR")
-		webhookToken = os.Getenv("WEBHOOK_TOKEN_ERROR")
-	}
-
-	baseService, err := base.NewBaseService(
-		config.MulaStorageFile,
-		config.MulaURL,
-		webhookID,
-		webhookToken,
-		0xFFDFBA, // Light orange color
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	return &Mula{
-		BaseService: baseService,
-	}, nil
-}
-
-func (m *Mula) FetchAndProcessStories() error {
-	return m.BaseService.FetchAndProcessStories(m.fetchStoryLinks, m.processStory)
+func init() {
+	registry.Register(registry.Definition{
+		Name:        "mula",
+		BaseURL:     config.MulaURL,
+		StorageFile: config.MulaStorageFile,
+		EmbedColor:  0xFFDFBA, // Light orange color
+		FetchLinks:  fetchStoryLinks,
+		ParseStory:  fetchAndParseStory,
+		PageURL:     registry.PageQuery,
+		// Replies often add the most useful details
+		WatchComments: true,
+	})
 }
 
-func (m *Mula) fetchStoryLinks() ([]string, error) {
-	req, err := http.NewRequest("GET", m.BaseURL, nil)
+func fetchStoryLinks(b *base.BaseService, listURL string) ([]string, error) {
+	req, err := http.NewRequest("GET", listURL, nil)
 	if err != nil {
 		return nil, errorhandling.NewError(errorhandling.NetworkError, "Failed to create request", err)
 	}
 
-	for key, value := range m.HTTPConfig.Headers {
+	for key, value := range b.HTTPConfig.Headers {
 		req.Header.Set(key, value)
 	}
-	resp, err := m.HTTPConfig.Client.Do(req)
+	resp, err := b.HTTPConfig.Client.Do(req)
 	if err != nil {
 		return nil, errorhandling.NewError(errorhandling.NetworkError, "Failed to fetch story links", err)
 	}
@@ -76,7 +54,7 @@ func (m *Mula) fetchStoryLinks() ([]string, error) {
 	doc.Find("a.text-decoration-none.hyper-link").Each(func(i int, s *goquery.Selection) {
 		if link, exists := s.Attr("href"); exists {
 			if link[0] == '/' {
-				link = m.BaseURL + link
+				link = b.BaseURL + link
 				links = append(links, link)
 			}
 		}
@@ -85,47 +63,13 @@ func (m *Mula) fetchStoryLinks() ([]string, error) {
 	return links, nil
 }
 
-func (m *Mula) processStory(link string) error {
-	if m.HasStory(strings.TrimPrefix(link, m.BaseURL+"/story/")) {
-		log.Println("Found no new story, skipping:", strings.TrimPrefix(link, m.BaseURL+"/story/"))
-		return nil
-	}
-
-	story, err := m.fetchAndParseStory(link)
-	if err != nil {
-		return errorhandling.NewError(errorhandling.ScrapingError, "Failed to fetch story", err)
-	}
-
-	if err := m.SendToDiscord(story); err != nil {
-		return err
-	}
-
-	if err := m.AddStory(strings.TrimPrefix(link, m.BaseURL+"/story/")); err != nil {
-		return errorhandling.NewError(errorhandling.StorageError, "Failed to mark story as sent", err)
-	}
-	return nil
-}
-
-func (m *Mula) fetchAndParseStory(link string) (*base.Story, error) {
-	req, err := http.NewRequest("GET", link, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	for key, value := range m.HTTPConfig.Headers {
-		req.Header.Set(key, value)
-	}
-
-	resp, err := m.HTTPConfig.Client.Do(req)
+func fetchAndParseStory(b *base.BaseService, link string) (*base.Story, error) {
+	resp, err := b.Fetch(link)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
-	}
-
 	doc, err := goquery.NewDocumentFromReader(resp.Body)
 	if err != nil {
 		return nil, err
@@ -140,6 +84,9 @@ func (m *Mula) fetchAndParseStory(link string) (*base.Story, error) {
 	authorText := doc.Find("h6.fw-semibold").Text()
 	story.Author = strings.TrimSpace(strings.TrimPrefix(authorText, "by "))
 
+	// Comments have their own <time> elements further down
+	story.PublishedAt = parseTimeElement(doc.Find("main time").Not(".comment time").First())
+
 	doc.Find(".badge").Each(func(i int, s *goquery.Selection) {
 		text := strings.TrimSpace(s.Text())
 		if i == 0 {
@@ -150,6 +97,9 @@ func (m *Mula) fetchAndParseStory(link string) (*base.Story, error) {
 	})
 
 	if len(story.Company) == 0 {
+		if len(story.Title) == 0 {
+			return nil, base.ErrStoryNotFound
+		}
 		return nil, errors.New("Empty company name")
 	}
 
@@ -175,6 +125,127 @@ func (m *Mula) fetchAndParseStory(link string) (*base.Story, error) {
 		})
 	})
 	story.Description = strings.TrimSpace(description.String())
+	story.Comments = parseComments(doc, link)
+	story.Votes = parseVotes(doc, link)
 
 	return story, nil
 }
+
+// parseVotes sums the vote and reaction counters of the story, ignoring the
+// ones of comments. Like the comment selectors these are not verified
+// against a real story page yet.
+func parseVotes(doc *goquery.Document, link string) int {
+	counters := doc.Find(".vote-count, .reaction-count").Not(".comment .vote-count, .comment .reaction-count")
+	if counters.Length() == 0 {
+		warnMissing("vote count", link)
+	}
+
+	votes := 0
+	counters.Each(func(i int, s *goquery.Selection) {
+		if n, err := strconv.Atoi(strings.TrimSpace(s.Text())); err == nil {
+			votes += n
+		}
+	})
+	return votes
+}
+
+// dateLayouts are the date formats stories and comments are shown with
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"January 2, 2006 3:04 PM",
+	"Jan 2, 2006 3:04 PM",
+	"02 Jan 2006, 03:04 PM",
+	"January 2, 2006",
+	"Jan 2, 2006",
+}
+
+// commentSection wraps the replies of a story, it is shown even when there
+// are none. The comment selectors are not verified against a real story page
+// yet, see the README.
+const commentSection = "#comments, .comments"
+
+// parseComments returns the replies under a story in page order
+func parseComments(doc *goquery.Document, link string) []base.Comment {
+	if doc.Find(commentSection).Length() == 0 {
+		warnMissing("comment section", link)
+	}
+
+	var comments []base.Comment
+	doc.Find(".comment").Each(func(i int, s *goquery.Selection) {
+		// Nested replies are visited on their own
+		text := strings.TrimSpace(s.Find(".comment-body").First().Text())
+		if text == "" {
+			return
+		}
+
+		comment := base.Comment{
+			Author: strings.TrimSpace(s.Find(".comment-author").First().Text()),
+			Text:   text,
+		}
+
+		date := s.Find("time").First()
+		comment.PostedAt = parseTimeElement(date)
+
+		comments = append(comments, comment)
+	})
+	return comments
+}
+
+// missingMarkupLimit bounds missingMarkup, it is cleared once full, so a
+// story may be reported again
+const missingMarkupLimit = 1000
+
+var (
+	// missingMarkup holds the elements already reported by warnMissing
+	missingMarkup   = make(map[string]bool)
+	missingMarkupMu sync.Mutex
+)
+
+// warnMissing logs once per story that an element expected on every story
+// page was not found, which usually means the selectors are outdated
+func warnMissing(element, link string) {
+	missingMarkupMu.Lock()
+	defer missingMarkupMu.Unlock()
+
+	key := element + " " + link
+	if missingMarkup[key] {
+		return
+	}
+	if len(missingMarkup) >= missingMarkupLimit {
+		clear(missingMarkup)
+	}
+	missingMarkup[key] = true
+	log.Printf("No %s found on %s, the mula selectors may be outdated", element, link)
+}
+
+// parseTimeElement parses the datetime attribute of a <time> element,
+// falling back to its text
+func parseTimeElement(s *goquery.Selection) time.Time {
+	value, exists := s.Attr("datetime")
+	if !exists {
+		value = s.Text()
+	}
+	return parseDate(strings.TrimSpace(value))
+}
+
+// siteLocation is the timezone of dates shown without one. Bangladesh has
+// no daylight saving time, so a fixed zone stands in when the system has no
+// timezone database.
+var siteLocation = func() *time.Location {
+	if loc, err := time.LoadLocation("Asia/Dhaka"); err == nil {
+		return loc
+	}
+	return time.FixedZone("BST", 6*60*60)
+}()
+
+// parseDate tries every known layout, returning the zero time if none fits.
+// Dates without a zone are in siteLocation.
+func parseDate(value string) time.Time {
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, value, siteLocation); err == nil {
+			return t.UTC()
+		}
+	}
+	return time.Time{}
+}
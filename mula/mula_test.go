@@ -0,0 +1,108 @@
+package mula
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/base"
+)
+
+func TestParseDateUsesBangladeshTime(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{"2024-03-01 10:00:00", time.Date(2024, 3, 1, 4, 0, 0, 0, time.UTC)},
+		{"March 1, 2024 10:00 AM", time.Date(2024, 3, 1, 4, 0, 0, 0, time.UTC)},
+		{"Mar 1, 2024", time.Date(2024, 2, 29, 18, 0, 0, 0, time.UTC)},
+		// An explicit zone wins
+		{"2024-03-01T10:00:00Z", time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)},
+		{"not a date", time.Time{}},
+	}
+
+	for _, tt := range tests {
+		if got := parseDate(tt.value); !got.Equal(tt.want) {
+			t.Errorf("parseDate(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+// loadPage parses a story page fixture
+func loadPage(t *testing.T, path string) *goquery.Document {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	doc, err := goquery.NewDocumentFromReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func TestParseComments(t *testing.T) {
+	doc := loadPage(t, "testdata/story.html")
+
+	comments := parseComments(doc, "https://deshimula.com/story/1")
+	want := []base.Comment{
+		{Author: "rahim", Text: "Same thing happened to me.", PostedAt: time.Date(2024, 3, 1, 6, 30, 0, 0, time.UTC)},
+		// Nested replies follow their parent
+		{Author: "karim", Text: "Which team were you on?", PostedAt: time.Date(2024, 3, 2, 3, 15, 0, 0, time.UTC)},
+		// The empty comment is skipped, an unparsable date is left zero
+		{Author: "nusrat", Text: "Thanks for sharing."},
+	}
+
+	if len(comments) != len(want) {
+		t.Fatalf("got %d comments, want %d: %+v", len(comments), len(want), comments)
+	}
+	for i := range want {
+		got := comments[i]
+		if got.Author != want[i].Author || got.Text != want[i].Text || !got.PostedAt.Equal(want[i].PostedAt) {
+			t.Errorf("comment %d = %+v, want %+v", i, got, want[i])
+		}
+	}
+}
+
+func TestWarnMissingIsBounded(t *testing.T) {
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	for i := 0; i < missingMarkupLimit+10; i++ {
+		warnMissing("comment section", fmt.Sprintf("https://deshimula.com/story/%d", i))
+	}
+
+	missingMarkupMu.Lock()
+	defer missingMarkupMu.Unlock()
+	if len(missingMarkup) > missingMarkupLimit {
+		t.Errorf("missingMarkup holds %d entries, want at most %d", len(missingMarkup), missingMarkupLimit)
+	}
+}
+
+func TestParseVotes(t *testing.T) {
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	// 12 votes and 3 reactions on the story, the counters of comments are
+	// not counted
+	doc := loadPage(t, "testdata/story.html")
+	if votes := parseVotes(doc, "https://deshimula.com/story/1"); votes != 15 {
+		t.Errorf("parseVotes = %d, want 15", votes)
+	}
+
+	empty, err := goquery.NewDocumentFromReader(strings.NewReader(`<main><h3>Title</h3></main>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if votes := parseVotes(empty, "https://deshimula.com/story/2"); votes != 0 {
+		t.Errorf("parseVotes without counters = %d, want 0", votes)
+	}
+}
@@ -0,0 +1,186 @@
+package notifier
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/base"
+	discordtexthook "github.com/nahidhasan98/discord-text-hook"
+)
+
+const (
+	discordMaxContentLength = 4000
+	discordAPIURL           = "https://discord.com/api/v9"
+	// discordThreadExists is the error code of starting a second thread
+	// on a message
+	discordThreadExists = 160004
+)
+
+// Discord sends stories as embeds to a Discord webhook. Webhooks cannot
+// start threads, with a bot token follow-ups are posted in a thread started
+// on the story message.
+type Discord struct {
+	WebhookID    string
+	WebhookToken string
+	EmbedColor   int
+	BotToken     string
+	client       *http.Client
+}
+
+// NewDiscord creates a Discord notifier
+func NewDiscord(webhookID, webhookToken string, embedColor int) *Discord {
+	return &Discord{
+		WebhookID:    webhookID,
+		WebhookToken: webhookToken,
+		EmbedColor:   embedColor,
+		client:       &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (d *Discord) Name() string {
+	return "discord"
+}
+
+// Send sends the story summary embed followed by the description in chunks
+func (d *Discord) Send(story *base.Story) error {
+	_, err := d.SendThreaded(story)
+	return err
+}
+
+// SendThreaded sends a story like Send and returns the channel and ID of
+// its summary message, empty without a bot token
+func (d *Discord) SendThreaded(story *base.Story) (string, error) {
+	webhook := discordtexthook.NewDiscordTextHookService(d.WebhookID, d.WebhookToken)
+
+	embeds := d.embeds(story)
+	message, err := webhook.SendEmbed(embeds[0])
+	if err != nil {
+		return "", fmt.Errorf("failed to send main embed: %w", err)
+	}
+
+	for _, embed := range embeds[1:] {
+		if _, err := webhook.SendEmbed(embed); err != nil {
+			return "", fmt.Errorf("failed to send description chunk: %w", err)
+		}
+	}
+
+	if d.BotToken == "" || message == nil || message.ID == "" {
+		return "", nil
+	}
+	return message.ChannelID + "/" + message.ID, nil
+}
+
+// Reply sends an event in the thread of the summary message of its story,
+// starting the thread on the first reply
+func (d *Discord) Reply(ref string, event *base.Event) error {
+	channelID, messageID, found := strings.Cut(ref, "/")
+	if !found {
+		return fmt.Errorf("invalid discord message reference %q", ref)
+	}
+
+	if err := d.startThread(channelID, messageID, event.Story.Title); err != nil {
+		return fmt.Errorf("failed to start thread: %w", err)
+	}
+
+	// A thread started on a message has the ID of the message
+	threadURL := discordAPIURL + "/webhooks/" + d.WebhookID + "/" + d.WebhookToken + "?thread_id=" + messageID
+	for _, embed := range d.embeds(event.AsStory()) {
+		payload := discordtexthook.WebhookPayload{Embeds: []discordtexthook.Embed{embed}}
+		if err := postJSON(d.client, threadURL, payload); err != nil {
+			return fmt.Errorf("failed to send reply: %w", err)
+		}
+	}
+	return nil
+}
+
+// startThread starts a thread on a message, a thread started earlier is
+// reused
+func (d *Discord) startThread(channelID, messageID, title string) error {
+	req, err := newJSONRequest("POST", discordAPIURL+"/channels/"+channelID+"/messages/"+messageID+"/threads",
+		map[string]string{"name": truncateString(title, 100)})
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bot "+d.BotToken)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	var apiErr struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&apiErr)
+	if apiErr.Code == discordThreadExists {
+		return nil
+	}
+	return fmt.Errorf("unexpected status code: %d: %s", resp.StatusCode, apiErr.Message)
+}
+
+// embeds renders the summary embed of a story followed by its description
+// in chunks
+func (d *Discord) embeds(story *base.Story) []discordtexthook.Embed {
+	description := fmt.Sprintf("**Author:** %s\n**Company:** %s\n**Tag:** %s\n**Link:** %s",
+		truncateString(story.Author, 1024),
+		truncateString(story.Company, 1024),
+		truncateString(story.Tag, 1024),
+		story.Link)
+	if !story.PublishedAt.IsZero() {
+		// The embed type has no timestamp, Discord renders this in the
+		// reader's timezone instead
+		description += fmt.Sprintf("\n**Published:** <t:%d:f>", story.PublishedAt.Unix())
+	}
+
+	embeds := []discordtexthook.Embed{{
+		Title:       "📢  " + truncateString(story.Title, 256),
+		Description: description,
+		Color:       d.EmbedColor,
+	}}
+
+	chunks := chunkText(story.Description, discordMaxContentLength)
+	for i, chunk := range chunks {
+		embeds = append(embeds, discordtexthook.Embed{
+			Title:       chunkTitle(i, len(chunks)),
+			Description: chunk,
+			Color:       d.EmbedColor,
+		})
+	}
+	return embeds
+}
+
+// SendError sends an error report as a markdown code block
+func (d *Discord) SendError(message string) error {
+	webhook := discordtexthook.NewDiscordTextHookService(d.WebhookID, d.WebhookToken)
+
+	_, err := webhook.SendMessage(fmt.Sprintf("```md\n%s```", message))
+	return err
+}
+
+// Health checks that the webhook exists
+func (d *Discord) Health() error {
+	if d.WebhookID == "" || d.WebhookToken == "" {
+		return fmt.Errorf("discord webhook configuration missing")
+	}
+
+	resp, err := d.client.Get("https://discord.com/api/v9/webhooks/" + d.WebhookID + "/" + d.WebhookToken)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
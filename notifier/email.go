@@ -0,0 +1,278 @@
+package notifier
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"html"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/base"
+)
+
+// EmailConfig holds the SMTP server settings
+type EmailConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+	To       []string
+	StartTLS bool
+}
+
+// Email sends stories as multipart (plain text and HTML) emails via SMTP
+type Email struct {
+	config EmailConfig
+}
+
+// NewEmail creates an email notifier
+func NewEmail(config EmailConfig) *Email {
+	return &Email{config: config}
+}
+
+func (e *Email) Name() string {
+	return "email"
+}
+
+// Send sends the story as one email
+func (e *Email) Send(story *base.Story) error {
+	subject := "📢 " + story.Title
+	if story.Company != "" {
+		subject = "📢 " + story.Company + ": " + story.Title
+	}
+
+	msg, err := e.buildMessage(subject, storyPlainText(story), storyHTML(story))
+	if err != nil {
+		return err
+	}
+	return e.send(msg)
+}
+
+// SendError sends an error report as a plain text email
+func (e *Email) SendError(message string) error {
+	msg, err := e.buildMessage("Notifier error", message, "<pre>"+html.EscapeString(message)+"</pre>")
+	if err != nil {
+		return err
+	}
+	return e.send(msg)
+}
+
+// Health checks that the SMTP server accepts connections
+func (e *Email) Health() error {
+	if e.config.Host == "" || e.config.From == "" || len(e.config.To) == 0 {
+		return fmt.Errorf("email configuration missing")
+	}
+
+	client, err := e.dial()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	return client.Quit()
+}
+
+func (e *Email) dial() (*smtp.Client, error) {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(e.config.Host, e.config.Port), 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := smtp.NewClient(conn, e.config.Host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if e.config.StartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			client.Close()
+			return nil, fmt.Errorf("smtp server does not support STARTTLS")
+		}
+		if err := client.StartTLS(&tls.Config{ServerName: e.config.Host}); err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+
+	if e.config.Username != "" {
+		auth := smtp.PlainAuth("", e.config.Username, e.config.Password, e.config.Host)
+		if err := client.Auth(auth); err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+
+	return client, nil
+}
+
+func (e *Email) send(msg []byte) error {
+	client, err := e.dial()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if err := client.Mail(e.config.From); err != nil {
+		return err
+	}
+	for _, to := range e.config.To {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+// buildMessage builds a multipart/alternative message with quoted-printable parts
+func (e *Email) buildMessage(subject, plainBody, htmlBody string) ([]byte, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+
+	for _, part := range []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=UTF-8", plainBody},
+		{"text/html; charset=UTF-8", htmlBody},
+	} {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", part.contentType)
+		header.Set("Content-Transfer-Encoding", "quoted-printable")
+
+		pw, err := mw.CreatePart(header)
+		if err != nil {
+			return nil, err
+		}
+		qw := quotedprintable.NewWriter(pw)
+		if _, err := qw.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err := qw.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", e.config.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(e.config.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", mw.Boundary())
+	msg.Write(body.Bytes())
+
+	return msg.Bytes(), nil
+}
+
+func storyPlainText(story *base.Story) string {
+	return fmt.Sprintf("%s\n\nAuthor: %s\nCompany: %s\nTag: %s\nLink: %s\n\n%s\n",
+		story.Title, story.Author, story.Company, story.Tag, story.Link, story.Description)
+}
+
+func storyHTML(story *base.Story) string {
+	var b strings.Builder
+	b.WriteString("<html><body>\n")
+	fmt.Fprintf(&b, "<h2>%s</h2>\n", html.EscapeString(story.Title))
+	fmt.Fprintf(&b, "<p><b>Author:</b> %s<br>\n<b>Company:</b> %s<br>\n<b>Tag:</b> %s<br>\n<b>Link:</b> <a href=\"%s\">%s</a></p>\n",
+		html.EscapeString(story.Author),
+		html.EscapeString(story.Company),
+		html.EscapeString(story.Tag),
+		html.EscapeString(story.Link),
+		html.EscapeString(story.Link))
+	b.WriteString("<hr>\n")
+	b.WriteString(descriptionHTML(story.Description))
+	b.WriteString("</body></html>\n")
+	return b.String()
+}
+
+// descriptionHTML converts the description markup produced by the parsers
+// ("### heading ###", "## heading ##", "- item") into HTML
+func descriptionHTML(description string) string {
+	var b strings.Builder
+	inList := false
+
+	for _, line := range strings.Split(description, "\n") {
+		line = strings.TrimSpace(line)
+		isItem := strings.HasPrefix(line, "- ")
+
+		if inList && !isItem {
+			b.WriteString("</ul>\n")
+			inList = false
+		}
+
+		switch {
+		case line == "":
+		case isItem:
+			if !inList {
+				b.WriteString("<ul>\n")
+				inList = true
+			}
+			fmt.Fprintf(&b, "<li>%s</li>\n", html.EscapeString(strings.TrimPrefix(line, "- ")))
+		case strings.HasPrefix(line, "### ") && strings.HasSuffix(line, " ###"):
+			fmt.Fprintf(&b, "<h3>%s</h3>\n", html.EscapeString(strings.TrimSuffix(strings.TrimPrefix(line, "### "), " ###")))
+		case strings.HasPrefix(line, "## ") && strings.HasSuffix(line, " ##"):
+			fmt.Fprintf(&b, "<h4>%s</h4>\n", html.EscapeString(strings.TrimSuffix(strings.TrimPrefix(line, "## "), " ##")))
+		default:
+			fmt.Fprintf(&b, "<p>%s</p>\n", html.EscapeString(line))
+		}
+	}
+	if inList {
+		b.WriteString("</ul>\n")
+	}
+
+	return b.String()
+}
+
+func emailFromEnv(source string, embedColor int) (base.Notifier, error) {
+	to := os.Getenv("EMAIL_TO_" + source)
+	if to == "" || os.Getenv("SMTP_HOST") == "" {
+		return nil, nil
+	}
+
+	var recipients []string
+	for _, addr := range strings.Split(to, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			recipients = append(recipients, addr)
+		}
+	}
+
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+
+	return NewEmail(EmailConfig{
+		Host:     os.Getenv("SMTP_HOST"),
+		Port:     port,
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     os.Getenv("SMTP_FROM"),
+		To:       recipients,
+		StartTLS: os.Getenv("SMTP_STARTTLS") != "false",
+	}), nil
+}
@@ -0,0 +1,253 @@
+package notifier
+
+import (
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// smtpServer is a minimal SMTP server recording the mails it accepts
+type smtpServer struct {
+	listener net.Listener
+	// auth advertises AUTH PLAIN and only accepts user/secret
+	auth bool
+
+	mu     sync.Mutex
+	authed bool
+	mails  []smtpMail
+}
+
+type smtpMail struct {
+	from string
+	to   []string
+	data string
+}
+
+func newSMTPServer(t *testing.T, auth bool) *smtpServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &smtpServer{listener: listener, auth: auth}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *smtpServer) port() string {
+	_, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	return port
+}
+
+func (s *smtpServer) serve(conn net.Conn) {
+	tp := textproto.NewConn(conn)
+	defer tp.Close()
+
+	var current smtpMail
+	tp.PrintfLine("220 localhost ESMTP")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			if s.auth {
+				tp.PrintfLine("250-localhost")
+				tp.PrintfLine("250 AUTH PLAIN")
+			} else {
+				tp.PrintfLine("250 localhost")
+			}
+		case "AUTH":
+			mechanism, response, _ := strings.Cut(arg, " ")
+			credentials, _ := base64.StdEncoding.DecodeString(response)
+			if !s.auth || mechanism != "PLAIN" || string(credentials) != "\x00user\x00secret" {
+				tp.PrintfLine("535 authentication failed")
+				continue
+			}
+			s.mu.Lock()
+			s.authed = true
+			s.mu.Unlock()
+			tp.PrintfLine("235 authenticated")
+		case "MAIL":
+			current = smtpMail{from: strings.Trim(strings.TrimPrefix(arg, "FROM:"), "<>")}
+			tp.PrintfLine("250 ok")
+		case "RCPT":
+			current.to = append(current.to, strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>"))
+			tp.PrintfLine("250 ok")
+		case "DATA":
+			tp.PrintfLine("354 go ahead")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			current.data = string(data)
+			s.mu.Lock()
+			s.mails = append(s.mails, current)
+			s.mu.Unlock()
+			tp.PrintfLine("250 queued")
+		case "QUIT":
+			tp.PrintfLine("221 bye")
+			return
+		default:
+			tp.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+func (s *smtpServer) sent() []smtpMail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]smtpMail(nil), s.mails...)
+}
+
+func (s *smtpServer) authenticated() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.authed
+}
+
+func TestEmailSendsMultipartMessage(t *testing.T) {
+	server := newSMTPServer(t, false)
+	email := NewEmail(EmailConfig{
+		Host: "127.0.0.1",
+		Port: server.port(),
+		From: "notifier@example.com",
+		To:   []string{"a@example.com", "b@example.com"},
+	})
+
+	story := testStory()
+	story.Description = "### Intro ###\nFish & chips, " + strings.Repeat("a long line ", 10) + "\n- one\n- two"
+	if err := email.Send(story); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	mails := server.sent()
+	if len(mails) != 1 {
+		t.Fatalf("server received %d mails, want 1", len(mails))
+	}
+	if mails[0].from != "notifier@example.com" || strings.Join(mails[0].to, ",") != "a@example.com,b@example.com" {
+		t.Errorf("envelope = %s -> %v, want the configured addresses", mails[0].from, mails[0].to)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(mails[0].data))
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != "📢 Company: Title" {
+		t.Errorf("subject = %q, %v, want the company and title", subject, err)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("content type = %q, %v, want multipart/alternative", mediaType, err)
+	}
+
+	parts := map[string]string{}
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("NextPart: %v", err)
+		}
+		// The reader decodes quoted-printable parts
+		body, err := io.ReadAll(part)
+		if err != nil {
+			t.Fatalf("reading part: %v", err)
+		}
+		contentType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		parts[contentType] = string(body)
+	}
+
+	if plain := parts["text/plain"]; !strings.Contains(plain, "Company: Company") || !strings.Contains(plain, story.Description) {
+		t.Errorf("plain text part = %q, want the story fields and the description unchanged", plain)
+	}
+	html := parts["text/html"]
+	for _, want := range []string{"<h3>Intro</h3>", "<p>Fish &amp; chips, ", "<li>one</li>", `<a href="https://example.com/story/42">`} {
+		if !strings.Contains(html, want) {
+			t.Errorf("HTML part does not contain %q:\n%s", want, html)
+		}
+	}
+}
+
+func TestEmailRequiresAdvertisedStartTLS(t *testing.T) {
+	server := newSMTPServer(t, false)
+	email := NewEmail(EmailConfig{
+		Host:     "127.0.0.1",
+		Port:     server.port(),
+		From:     "notifier@example.com",
+		To:       []string{"a@example.com"},
+		StartTLS: true,
+	})
+
+	err := email.Send(testStory())
+	if err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Errorf("Send = %v, want a missing STARTTLS error", err)
+	}
+	if len(server.sent()) != 0 {
+		t.Error("mail sent over a connection that could not be secured")
+	}
+}
+
+func TestEmailAuthenticates(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		wantErr  bool
+	}{
+		{"valid credentials", "secret", false},
+		{"wrong password", "wrong", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newSMTPServer(t, true)
+			// PLAIN auth is only allowed without TLS against localhost
+			email := NewEmail(EmailConfig{
+				Host:     "127.0.0.1",
+				Port:     server.port(),
+				Username: "user",
+				Password: tt.password,
+				From:     "notifier@example.com",
+				To:       []string{"a@example.com"},
+			})
+
+			err := email.Send(testStory())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Send = %v, want error %v", err, tt.wantErr)
+			}
+			wantSent := 1
+			if tt.wantErr {
+				wantSent = 0
+			}
+			if sent := len(server.sent()); sent != wantSent {
+				t.Errorf("server received %d mails, want %d", sent, wantSent)
+			}
+			if server.authenticated() == tt.wantErr {
+				t.Errorf("authenticated = %v, want %v", !tt.wantErr, tt.wantErr)
+			}
+		})
+	}
+}
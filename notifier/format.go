@@ -0,0 +1,75 @@
+package notifier
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// truncateString truncates a string to the specified maximum number of
+// characters
+func truncateString(s string, maxLength int) string {
+	if utf8.RuneCountInString(s) <= maxLength {
+		return s
+	}
+	return s[:runeOffset(s, maxLength-3)] + "..."
+}
+
+// chunkText splits text into chunks of at most maxLength characters,
+// preferring to cut at the last newline, then at the last space, so neither
+// words nor multi-byte characters (e.g. Bangla text) are split. Empty chunks
+// are dropped.
+func chunkText(text string, maxLength int) []string {
+	var chunks []string
+
+	for len(text) > 0 {
+		chunk := text
+		if utf8.RuneCountInString(text) > maxLength {
+			limit := runeOffset(text, maxLength)
+			cut := strings.LastIndex(text[:limit], "\n")
+			if cut == -1 {
+				cut = strings.LastIndexAny(text[:limit], " \t")
+			}
+			if cut == -1 {
+				// A single long word, cut at the last character that fits
+				chunk = text[:limit]
+				text = text[limit:]
+			} else {
+				chunk = text[:cut]
+				text = text[cut+1:] // Skip the separator
+			}
+		} else {
+			// This is the last chunk
+			text = ""
+		}
+
+		// Skip empty chunks
+		chunk = strings.TrimSpace(chunk)
+		if chunk == "" {
+			continue
+		}
+		chunks = append(chunks, chunk)
+	}
+
+	return chunks
+}
+
+// runeOffset returns the byte offset of the n-th character of s, or len(s)
+// if s is shorter
+func runeOffset(s string, n int) int {
+	for offset := range s {
+		if n == 0 {
+			return offset
+		}
+		n--
+	}
+	return len(s)
+}
+
+// chunkTitle returns the title of a description chunk
+func chunkTitle(index, total int) string {
+	if total > 1 {
+		return fmt.Sprintf("Review/Description (Part %d)", index+1)
+	}
+	return "Review/Description"
+}
@@ -0,0 +1,59 @@
+package notifier
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestChunkText(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		maxLength int
+		want      []string
+	}{
+		{"short", "hello", 10, []string{"hello"}},
+		{"newline", "first line\nsecond", 12, []string{"first line", "second"}},
+		{"space", "one two three", 8, []string{"one two", "three"}},
+		{"long word", "abcdefgh", 3, []string{"abc", "def", "gh"}},
+		{"bangla", "আমি বাংলায় গান গাই", 7, []string{"আমি", "বাংলায়", "গান", "গাই"}},
+		{"bangla word", "বাংলায়", 3, []string{"বাং", "লায", "়"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := chunkText(tt.text, tt.maxLength)
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Fatalf("chunkText(%q, %d) = %q, want %q", tt.text, tt.maxLength, got, tt.want)
+			}
+			for _, chunk := range got {
+				if !utf8.ValidString(chunk) {
+					t.Errorf("chunk %q is not valid UTF-8", chunk)
+				}
+			}
+		})
+	}
+}
+
+func TestTruncateString(t *testing.T) {
+	got := truncateString("বাংলাদেশ", 6)
+	if got != "বাং..." {
+		t.Fatalf("truncateString = %q, want %q", got, "বাং...")
+	}
+	if !utf8.ValidString(got) {
+		t.Fatalf("truncateString returned invalid UTF-8 %q", got)
+	}
+}
+
+func TestSlackChunksEscapeAfterSplitting(t *testing.T) {
+	text := strings.Repeat("a&b ", 1000)
+	for _, chunk := range slackChunks(text, slackMaxContentLength) {
+		if utf8.RuneCountInString(chunk) > slackMaxContentLength {
+			t.Fatalf("chunk of %d characters exceeds the limit", utf8.RuneCountInString(chunk))
+		}
+		if strings.Contains(strings.ReplaceAll(chunk, "&amp;", ""), "&") {
+			t.Fatalf("chunk contains a cut entity: %q", chunk[len(chunk)-10:])
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package notifier
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/base"
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/errorhandling"
+)
+
+const (
+	jsonlDefaultMaxSize    = 10 * 1024 * 1024
+	jsonlDefaultMaxBackups = 5
+)
+
+// JSONLines writes every story as one JSON object per line to stdout or a
+// size-rotated file
+type JSONLines struct {
+	Source string
+	writer *jsonlWriter
+}
+
+// jsonlRecord is a story line, the source and scrape time come with the
+// embedded story
+type jsonlRecord struct {
+	Event  string `json:"event"`
+	Detail string `json:"detail,omitempty"`
+	// Comment is set for comment events
+	Comment *base.Comment `json:"comment,omitempty"`
+	*base.Story
+}
+
+type jsonlError struct {
+	Source string    `json:"source"`
+	Time   time.Time `json:"time"`
+	Error  string    `json:"error"`
+}
+
+// jsonlWriter serializes writes to one output shared by all sources
+type jsonlWriter struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+	out        io.Writer
+	file       *os.File
+	size       int64
+	mu         sync.Mutex
+}
+
+var (
+	jsonlWriters   = make(map[string]*jsonlWriter)
+	jsonlWritersMu sync.Mutex
+)
+
+// NewJSONLines creates a JSON Lines notifier. path "-" writes to stdout,
+// otherwise the file is rotated once it exceeds maxSize bytes (0 disables
+// rotation).
+func NewJSONLines(source, path string, maxSize int64, maxBackups int) *JSONLines {
+	jsonlWritersMu.Lock()
+	defer jsonlWritersMu.Unlock()
+
+	writer, exists := jsonlWriters[path]
+	if !exists {
+		writer = &jsonlWriter{
+			path:       path,
+			maxSize:    maxSize,
+			maxBackups: maxBackups,
+		}
+		if path == "-" {
+			writer.out = os.Stdout
+		}
+		jsonlWriters[path] = writer
+	}
+
+	return &JSONLines{
+		Source: source,
+		writer: writer,
+	}
+}
+
+func (j *JSONLines) Name() string {
+	return "jsonl"
+}
+
+// Passive keeps the log from marking a story as sent on its own
+func (j *JSONLines) Passive() bool {
+	return true
+}
+
+// Send writes the story as one line
+func (j *JSONLines) Send(story *base.Story) error {
+	return j.writer.write(jsonlRecord{
+		Event: "new",
+		Story: story,
+	})
+}
+
+// SendEvent writes the changed story and the change as one line
+func (j *JSONLines) SendEvent(event *base.Event) error {
+	return j.writer.write(jsonlRecord{
+		Event:   string(event.Kind),
+		Detail:  event.Detail,
+		Comment: event.Comment,
+		Story:   event.Story,
+	})
+}
+
+// SendError writes the error report as one line
+func (j *JSONLines) SendError(message string) error {
+	return j.writer.write(jsonlError{
+		Source: j.Source,
+		Time:   time.Now().UTC(),
+		Error:  message,
+	})
+}
+
+// Health checks that the output can be opened
+func (j *JSONLines) Health() error {
+	j.writer.mu.Lock()
+	defer j.writer.mu.Unlock()
+
+	return j.writer.open()
+}
+
+func (w *jsonlWriter) write(v interface{}) error {
+	line, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.open(); err != nil {
+		return err
+	}
+	if w.file != nil && w.maxSize > 0 && w.size+int64(len(line)) > w.maxSize && w.size > 0 {
+		if err := w.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := w.out.Write(line)
+	w.size += int64(n)
+	return err
+}
+
+// open opens the output file if needed
+func (w *jsonlWriter) open() error {
+	if w.out != nil {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(w.path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	w.file = file
+	w.out = file
+	w.size = info.Size()
+	return nil
+}
+
+// rotate shifts path.1 .. path.N-1 up by one, moves the current file to
+// path.1 and starts a new file
+func (w *jsonlWriter) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	w.file = nil
+	w.out = nil
+
+	for i := w.maxBackups - 1; i >= 1; i-- {
+		src := w.path + "." + strconv.Itoa(i)
+		if _, err := os.Stat(src); err == nil {
+			if err := os.Rename(src, w.path+"."+strconv.Itoa(i+1)); err != nil {
+				return err
+			}
+		}
+	}
+	if w.maxBackups > 0 {
+		if err := os.Rename(w.path, w.path+".1"); err != nil {
+			return err
+		}
+	} else if err := os.Remove(w.path); err != nil {
+		return err
+	}
+
+	return w.open()
+}
+
+func jsonlFromEnv(source string, embedColor int) (base.Notifier, error) {
+	path := os.Getenv("JSONL_OUTPUT")
+	if path == "" {
+		return nil, nil
+	}
+
+	maxSize := int64(jsonlDefaultMaxSize)
+	if value := os.Getenv("JSONL_MAX_SIZE"); value != "" {
+		size, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, errorhandling.NewError(errorhandling.ConfigError, "Invalid JSONL_MAX_SIZE", err)
+		}
+		maxSize = size
+	}
+
+	maxBackups := jsonlDefaultMaxBackups
+	if value := os.Getenv("JSONL_MAX_BACKUPS"); value != "" {
+		backups, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, errorhandling.NewError(errorhandling.ConfigError, "Invalid JSONL_MAX_BACKUPS", err)
+		}
+		maxBackups = backups
+	}
+
+	return NewJSONLines(strings.ToLower(source), path, maxSize, maxBackups), nil
+}
@@ -0,0 +1,71 @@
+package notifier
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// jsonlTitles returns the story titles written to path, "" if it does not
+// exist
+func jsonlTitles(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return ""
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var titles []string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var record struct {
+			Title string `json:"title"`
+		}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("invalid line %q: %v", line, err)
+		}
+		titles = append(titles, record.Title)
+	}
+	return strings.Join(titles, " ")
+}
+
+func TestJSONLinesRotation(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxBackups int
+		// want maps the file suffixes to the stories they hold
+		want map[string]string
+	}{
+		{"backups are shifted and capped", 2, map[string]string{"": "5", ".1": "3 4", ".2": "1 2", ".3": ""}},
+		{"no backups", 0, map[string]string{"": "5", ".1": ""}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			story := testStory()
+			line, _ := json.Marshal(jsonlRecord{Event: "new", Story: story})
+
+			// Two lines fit into a file
+			path := filepath.Join(t.TempDir(), "stories.jsonl")
+			jsonl := NewJSONLines("mula", path, int64(2*(len(line)+1)), tt.maxBackups)
+
+			for i := 1; i <= 5; i++ {
+				story.Title = strconv.Itoa(i)
+				if err := jsonl.Send(story); err != nil {
+					t.Fatalf("Send: %v", err)
+				}
+			}
+
+			for suffix, want := range tt.want {
+				if got := jsonlTitles(t, path+suffix); got != want {
+					t.Errorf("stories%s = %q, want %q", suffix, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,310 @@
+package notifier
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/base"
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/errorhandling"
+)
+
+// Headers set on every JSON webhook delivery. The signature is the hex
+// encoded HMAC-SHA256 of "<timestamp>.<body>" using the shared secret.
+const (
+	JSONWebhookSignatureHeader = "X-Notifier-Signature"
+	JSONWebhookTimestampHeader = "X-Notifier-Timestamp"
+	JSONWebhookDeliveryHeader  = "X-Notifier-Delivery"
+)
+
+const (
+	// jsonWebhookAttempts is how often a delivery is tried per URL
+	jsonWebhookAttempts = 3
+	// jsonWebhookRetryDelay is the pause before the first retry, it grows
+	// with every attempt
+	jsonWebhookRetryDelay = 2 * time.Second
+	// jsonWebhookDeliveryLimit bounds the remembered deliveries, they are
+	// cleared once full, so a story sent again much later gets a new ID
+	jsonWebhookDeliveryLimit = 1000
+)
+
+// JSONWebhook POSTs raw story data as signed JSON to arbitrary URLs
+type JSONWebhook struct {
+	URLs       []string
+	Secret     string
+	Source     string
+	client     *http.Client
+	retryDelay time.Duration
+
+	mu sync.Mutex
+	// deliveries maps what was delivered to its delivery, see deliveryKey
+	deliveries map[string]*jsonDelivery
+	// retries tracks the retries running in the background
+	retries sync.WaitGroup
+}
+
+// jsonDelivery is one story or event delivered to the webhook URLs
+type jsonDelivery struct {
+	id       string
+	accepted map[string]bool
+}
+
+type jsonWebhookPayload struct {
+	Event      string        `json:"event"`
+	Source     string        `json:"source"`
+	DeliveryID string        `json:"delivery_id"`
+	Timestamp  int64         `json:"timestamp"`
+	Story      *base.Story   `json:"story,omitempty"`
+	Comment    *base.Comment `json:"comment,omitempty"`
+	Detail     string        `json:"detail,omitempty"`
+	Message    string        `json:"message,omitempty"`
+}
+
+// NewJSONWebhook creates a JSON webhook notifier
+func NewJSONWebhook(urls []string, secret string, source string) *JSONWebhook {
+	return &JSONWebhook{
+		URLs:       urls,
+		Secret:     secret,
+		Source:     source,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		retryDelay: jsonWebhookRetryDelay,
+		deliveries: make(map[string]*jsonDelivery),
+	}
+}
+
+func (j *JSONWebhook) Name() string {
+	return "json-webhook"
+}
+
+// Send delivers the story to every URL
+func (j *JSONWebhook) Send(story *base.Story) error {
+	return j.deliver(jsonWebhookPayload{Event: "story", Story: story})
+}
+
+// SendEvent delivers a change to an already sent story to every URL
+func (j *JSONWebhook) SendEvent(event *base.Event) error {
+	return j.deliver(jsonWebhookPayload{Event: string(event.Kind), Story: event.Story, Comment: event.Comment, Detail: event.Detail})
+}
+
+// SendError delivers an error report to every URL
+func (j *JSONWebhook) SendError(message string) error {
+	return j.deliver(jsonWebhookPayload{Event: "error", Message: message})
+}
+
+// Health checks the webhook configuration
+func (j *JSONWebhook) Health() error {
+	if len(j.URLs) == 0 || j.Secret == "" {
+		return fmt.Errorf("json webhook configuration missing")
+	}
+	return nil
+}
+
+// deliver POSTs the payload to every URL in parallel. A story sent again
+// after a failure keeps its delivery ID and skips the URLs that already
+// accepted it. Failed attempts are retried in the background with the same
+// ID, so receivers can deduplicate them and the other notifiers do not wait
+// for the backoff.
+func (j *JSONWebhook) deliver(payload jsonWebhookPayload) error {
+	delivery, err := j.delivery(deliveryKey(payload))
+	if err != nil {
+		return err
+	}
+	payload.Source = j.Source
+	payload.DeliveryID = delivery.id
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for _, url := range j.URLs {
+		if j.accepted(delivery, url) {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			retry, err := j.attempt(url, payload)
+			if err == nil {
+				j.accept(delivery, url)
+				return
+			}
+			if retry {
+				j.retries.Add(1)
+				go j.retry(url, payload, delivery)
+			}
+			mu.Lock()
+			errs = append(errs, fmt.Errorf("failed to deliver to %s: %w", url, err))
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// retry tries a failed URL again with a growing pause, until it accepts the
+// delivery, rejects it or jsonWebhookAttempts is reached
+func (j *JSONWebhook) retry(url string, payload jsonWebhookPayload, delivery *jsonDelivery) {
+	defer j.retries.Done()
+	for attempt := 2; attempt <= jsonWebhookAttempts; attempt++ {
+		time.Sleep(time.Duration(attempt-1) * j.retryDelay)
+		// The story may have been sent again in the meantime
+		if j.accepted(delivery, url) {
+			return
+		}
+
+		retry, err := j.attempt(url, payload)
+		if err == nil {
+			j.accept(delivery, url)
+			return
+		}
+		if !retry || attempt == jsonWebhookAttempts {
+			log.Printf("Gave up delivering %s to %s: %v", payload.DeliveryID, url, err)
+			return
+		}
+	}
+}
+
+// attempt POSTs the payload to one URL, signed with a fresh timestamp so
+// retries are not rejected as stale
+func (j *JSONWebhook) attempt(url string, payload jsonWebhookPayload) (bool, error) {
+	payload.Timestamp = time.Now().Unix()
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return false, err
+	}
+
+	timestamp := strconv.FormatInt(payload.Timestamp, 10)
+	return j.post(url, body, timestamp, SignJSONWebhook(j.Secret, timestamp, body), payload.DeliveryID)
+}
+
+// deliveryKey identifies what a payload delivers, "" for error reports,
+// which are new every time
+func deliveryKey(payload jsonWebhookPayload) string {
+	if payload.Story == nil {
+		return ""
+	}
+	key := payload.Event + "\x00" + payload.Story.ID + "\x00" + payload.Story.Link + "\x00" + payload.Detail
+	if payload.Comment != nil {
+		key += "\x00" + payload.Comment.Key()
+	}
+	return key
+}
+
+// delivery returns the delivery of key, a new one with a random ID if it
+// was not sent before
+func (j *JSONWebhook) delivery(key string) (*jsonDelivery, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if delivery, ok := j.deliveries[key]; ok && key != "" {
+		return delivery, nil
+	}
+	id, err := newDeliveryID()
+	if err != nil {
+		return nil, err
+	}
+	delivery := &jsonDelivery{id: id, accepted: make(map[string]bool)}
+	if key != "" {
+		if len(j.deliveries) >= jsonWebhookDeliveryLimit {
+			clear(j.deliveries)
+		}
+		j.deliveries[key] = delivery
+	}
+	return delivery, nil
+}
+
+// accepted reports whether url accepted the delivery
+func (j *JSONWebhook) accepted(delivery *jsonDelivery, url string) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return delivery.accepted[url]
+}
+
+// accept records that url accepted the delivery
+func (j *JSONWebhook) accept(delivery *jsonDelivery, url string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	delivery.accepted[url] = true
+}
+
+// post sends one attempt and reports whether a failure is worth retrying:
+// network errors, rate limits and server errors are, rejections are not
+func (j *JSONWebhook) post(url string, body []byte, timestamp, signature, deliveryID string) (bool, error) {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(JSONWebhookSignatureHeader, "sha256="+signature)
+	req.Header.Set(JSONWebhookTimestampHeader, timestamp)
+	req.Header.Set(JSONWebhookDeliveryHeader, deliveryID)
+
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		return retry, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return false, nil
+}
+
+// SignJSONWebhook returns the hex encoded signature of a delivery, so
+// consumers written in Go can verify it with hmac.Equal
+func SignJSONWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// newDeliveryID returns a random ID, for JSON webhook deliveries and Matrix
+// transaction IDs
+func newDeliveryID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func jsonWebhookFromEnv(source string, embedColor int) (base.Notifier, error) {
+	urls := os.Getenv("JSON_WEBHOOK_URLS_" + source)
+	if urls == "" {
+		return nil, nil
+	}
+
+	secret := os.Getenv("JSON_WEBHOOK_SECRET_" + source)
+	if secret == "" {
+		secret = os.Getenv("JSON_WEBHOOK_SECRET")
+	}
+	if secret == "" {
+		return nil, errorhandling.NewError(errorhandling.ConfigError, "Missing JSON webhook secret for "+source, nil)
+	}
+
+	var targets []string
+	for _, url := range strings.Split(urls, ",") {
+		if url = strings.TrimSpace(url); url != "" {
+			targets = append(targets, url)
+		}
+	}
+
+	return NewJSONWebhook(targets, secret, strings.ToLower(source)), nil
+}
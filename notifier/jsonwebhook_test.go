@@ -0,0 +1,182 @@
+package notifier
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/base"
+)
+
+// webhookRecorder is a webhook receiver that records every request and
+// fails the first failures of them
+type webhookRecorder struct {
+	mu         sync.Mutex
+	deliveries []string
+	bodies     [][]byte
+	failures   int
+}
+
+func (r *webhookRecorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.deliveries = append(r.deliveries, req.Header.Get(JSONWebhookDeliveryHeader))
+	r.bodies = append(r.bodies, body)
+
+	signature := "sha256=" + SignJSONWebhook("secret", req.Header.Get(JSONWebhookTimestampHeader), body)
+	if req.Header.Get(JSONWebhookSignatureHeader) != signature {
+		http.Error(w, "bad signature", http.StatusUnauthorized)
+		return
+	}
+	if r.failures > 0 {
+		r.failures--
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func testStory() *base.Story {
+	return &base.Story{
+		ID:          "42",
+		Source:      "mula",
+		Title:       "Title",
+		Company:     "Company",
+		Tag:         "Tag",
+		Description: "Description",
+		Link:        "https://example.com/story/42",
+	}
+}
+
+func TestJSONWebhookSend(t *testing.T) {
+	recorder := &webhookRecorder{}
+	server := httptest.NewServer(recorder)
+	defer server.Close()
+
+	webhook := NewJSONWebhook([]string{server.URL}, "secret", "mula")
+	if err := webhook.Send(testStory()); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	var payload jsonWebhookPayload
+	if err := json.Unmarshal(recorder.bodies[0], &payload); err != nil {
+		t.Fatalf("invalid payload: %v", err)
+	}
+	if payload.Event != "story" || payload.Source != "mula" || payload.Story.ID != "42" {
+		t.Errorf("unexpected payload %+v", payload)
+	}
+	if payload.DeliveryID != recorder.deliveries[0] {
+		t.Errorf("delivery ID %q does not match header %q", payload.DeliveryID, recorder.deliveries[0])
+	}
+}
+
+func TestJSONWebhookRetriesWithTheSameDeliveryID(t *testing.T) {
+	tests := []struct {
+		name         string
+		secret       string
+		failures     int
+		wantAttempts int
+		// wantAccepted is whether the retries got the story through, so
+		// sending it again posts nothing
+		wantAccepted bool
+	}{
+		{"recovers", "secret", 2, 3, true},
+		{"gives up", "secret", 10, jsonWebhookAttempts, false},
+		// A rejected signature is not retried
+		{"rejected", "wrong", 0, 1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := &webhookRecorder{failures: tt.failures}
+			server := httptest.NewServer(recorder)
+			defer server.Close()
+
+			webhook := NewJSONWebhook([]string{server.URL}, tt.secret, "mula")
+			webhook.retryDelay = time.Millisecond
+
+			if err := webhook.Send(testStory()); err == nil {
+				t.Fatal("Send succeeded, want the first attempt to fail")
+			}
+			webhook.retries.Wait()
+			if len(recorder.deliveries) != tt.wantAttempts {
+				t.Fatalf("received %d attempts, want %d", len(recorder.deliveries), tt.wantAttempts)
+			}
+
+			webhook.Send(testStory())
+			webhook.retries.Wait()
+			if accepted := len(recorder.deliveries) == tt.wantAttempts; accepted != tt.wantAccepted {
+				t.Errorf("sending again posted %d times, want the story accepted %v", len(recorder.deliveries)-tt.wantAttempts, tt.wantAccepted)
+			}
+			for _, id := range recorder.deliveries {
+				if id != recorder.deliveries[0] {
+					t.Errorf("delivery IDs differ between attempts: %v", recorder.deliveries)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestJSONWebhookResendsOnlyToFailedURLs(t *testing.T) {
+	working := &webhookRecorder{}
+	failing := &webhookRecorder{failures: 1}
+	workingServer := httptest.NewServer(working)
+	defer workingServer.Close()
+	failingServer := httptest.NewServer(failing)
+	defer failingServer.Close()
+
+	webhook := NewJSONWebhook([]string{workingServer.URL, failingServer.URL}, "secret", "mula")
+	// Leave the retry to the next Send
+	webhook.retryDelay = time.Hour
+
+	if err := webhook.Send(testStory()); err == nil {
+		t.Fatal("Send succeeded, want the failing URL reported")
+	}
+	if err := webhook.Send(testStory()); err != nil {
+		t.Fatalf("Send again: %v", err)
+	}
+
+	if len(working.deliveries) != 1 {
+		t.Errorf("the working URL received %d deliveries, want 1", len(working.deliveries))
+	}
+	if len(failing.deliveries) != 2 || failing.deliveries[1] != working.deliveries[0] {
+		t.Errorf("the failing URL received %v, want the delivery %s again", failing.deliveries, working.deliveries[0])
+	}
+}
+
+func TestJSONWebhookDeliveriesHaveTheirOwnID(t *testing.T) {
+	recorder := &webhookRecorder{}
+	server := httptest.NewServer(recorder)
+	defer server.Close()
+
+	webhook := NewJSONWebhook([]string{server.URL, server.URL}, "secret", "mula")
+	other := testStory()
+	other.ID = "43"
+	for _, send := range []func() error{
+		func() error { return webhook.Send(testStory()) },
+		func() error { return webhook.Send(other) },
+		func() error { return webhook.SendEvent(&base.Event{Kind: base.StoryUpdated, Story: testStory(), Detail: "Title: A → B"}) },
+		func() error { return webhook.SendError("error") },
+	} {
+		if err := send(); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+
+	// Both URLs get the same delivery, every story and event is a new one
+	ids := make(map[string]int)
+	for _, id := range recorder.deliveries {
+		ids[id]++
+	}
+	if len(recorder.deliveries) != 8 || len(ids) != 4 {
+		t.Errorf("delivery IDs = %v, want one per Send", recorder.deliveries)
+	}
+}
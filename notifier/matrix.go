@@ -0,0 +1,159 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/base"
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/errorhandling"
+)
+
+// Matrix events are limited to 64 KiB, the HTML body roughly doubles the text
+const matrixMaxContentLength = 16000
+
+// Matrix sends stories as m.room.message events to a Matrix room
+type Matrix struct {
+	HomeserverURL string
+	AccessToken   string
+	RoomID        string
+	client        *http.Client
+}
+
+type matrixMessage struct {
+	MsgType       string `json:"msgtype"`
+	Body          string `json:"body"`
+	Format        string `json:"format,omitempty"`
+	FormattedBody string `json:"formatted_body,omitempty"`
+}
+
+// NewMatrix creates a Matrix notifier
+func NewMatrix(homeserverURL, accessToken, roomID string) *Matrix {
+	return &Matrix{
+		HomeserverURL: strings.TrimSuffix(homeserverURL, "/"),
+		AccessToken:   accessToken,
+		RoomID:        roomID,
+		client:        &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (m *Matrix) Name() string {
+	return "matrix"
+}
+
+// Send sends the story summary followed by the description in chunks
+func (m *Matrix) Send(story *base.Story) error {
+	summary := matrixMessage{
+		MsgType: "m.text",
+		Body: fmt.Sprintf("📢  %s\n\nAuthor: %s\nCompany: %s\nTag: %s\nLink: %s",
+			story.Title, story.Author, story.Company, story.Tag, story.Link),
+		Format: "org.matrix.custom.html",
+		FormattedBody: fmt.Sprintf("<h3>📢  %s</h3>\n<p><b>Author:</b> %s<br>\n<b>Company:</b> %s<br>\n<b>Tag:</b> %s<br>\n<b>Link:</b> <a href=\"%s\">%s</a></p>",
+			html.EscapeString(truncateString(story.Title, 256)),
+			html.EscapeString(truncateString(story.Author, 1024)),
+			html.EscapeString(truncateString(story.Company, 1024)),
+			html.EscapeString(truncateString(story.Tag, 1024)),
+			html.EscapeString(story.Link),
+			html.EscapeString(story.Link)),
+	}
+
+	if err := m.sendMessage(summary); err != nil {
+		return fmt.Errorf("failed to send summary: %w", err)
+	}
+
+	chunks := chunkText(story.Description, matrixMaxContentLength)
+	for i, chunk := range chunks {
+		title := chunkTitle(i, len(chunks))
+		message := matrixMessage{
+			MsgType:       "m.text",
+			Body:          title + "\n\n" + chunk,
+			Format:        "org.matrix.custom.html",
+			FormattedBody: "<h4>" + html.EscapeString(title) + "</h4>\n" + descriptionHTML(chunk),
+		}
+
+		if err := m.sendMessage(message); err != nil {
+			return fmt.Errorf("failed to send description chunk: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// SendError sends an error report as a notice with a code block
+func (m *Matrix) SendError(message string) error {
+	return m.sendMessage(matrixMessage{
+		MsgType:       "m.notice",
+		Body:          message,
+		Format:        "org.matrix.custom.html",
+		FormattedBody: "<pre><code>" + html.EscapeString(message) + "</code></pre>",
+	})
+}
+
+// Health checks the access token with whoami
+func (m *Matrix) Health() error {
+	if m.HomeserverURL == "" || m.AccessToken == "" || m.RoomID == "" {
+		return fmt.Errorf("matrix configuration missing")
+	}
+
+	req, err := http.NewRequest("GET", m.HomeserverURL+"/_matrix/client/v3/account/whoami", nil)
+	if err != nil {
+		return err
+	}
+	return m.do(req)
+}
+
+func (m *Matrix) sendMessage(message matrixMessage) error {
+	txnID, err := newDeliveryID()
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	endpoint := fmt.Sprintf("%s/_matrix/client/v3/rooms/%s/send/m.room.message/%s",
+		m.HomeserverURL, url.PathEscape(m.RoomID), txnID)
+	req, err := http.NewRequest("PUT", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	return m.do(req)
+}
+
+func (m *Matrix) do(req *http.Request) error {
+	req.Header.Set("Authorization", "Bearer "+m.AccessToken)
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status code: %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
+
+func matrixFromEnv(source string, embedColor int) (base.Notifier, error) {
+	roomID := os.Getenv("MATRIX_ROOM_ID_" + source)
+	if roomID == "" || os.Getenv("MATRIX_HOMESERVER_URL") == "" {
+		return nil, nil
+	}
+	if os.Getenv("MATRIX_ACCESS_TOKEN") == "" {
+		return nil, errorhandling.NewError(errorhandling.ConfigError, "Missing Matrix access token", nil)
+	}
+	return NewMatrix(os.Getenv("MATRIX_HOMESERVER_URL"), os.Getenv("MATRIX_ACCESS_TOKEN"), roomID), nil
+}
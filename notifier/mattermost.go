@@ -0,0 +1,119 @@
+package notifier
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/base"
+)
+
+const mattermostMaxContentLength = 4000
+
+// Mattermost sends stories as message attachments to a Mattermost
+// incoming webhook
+type Mattermost struct {
+	WebhookURL string
+	Color      string
+	client     *http.Client
+}
+
+type mattermostField struct {
+	Short bool   `json:"short"`
+	Title string `json:"title"`
+	Value string `json:"value"`
+}
+
+type mattermostAttachment struct {
+	Fallback  string            `json:"fallback"`
+	Color     string            `json:"color,omitempty"`
+	Title     string            `json:"title,omitempty"`
+	TitleLink string            `json:"title_link,omitempty"`
+	Text      string            `json:"text,omitempty"`
+	Fields    []mattermostField `json:"fields,omitempty"`
+}
+
+type mattermostPayload struct {
+	Text        string                 `json:"text,omitempty"`
+	Attachments []mattermostAttachment `json:"attachments,omitempty"`
+}
+
+// NewMattermost creates a Mattermost notifier
+func NewMattermost(webhookURL string, embedColor int) *Mattermost {
+	return &Mattermost{
+		WebhookURL: webhookURL,
+		Color:      fmt.Sprintf("#%06X", embedColor),
+		client:     &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (m *Mattermost) Name() string {
+	return "mattermost"
+}
+
+// Send sends the story summary followed by the description in chunks
+func (m *Mattermost) Send(story *base.Story) error {
+	title := "📢  " + truncateString(story.Title, 256)
+	summary := mattermostAttachment{
+		Fallback:  title,
+		Color:     m.Color,
+		Title:     title,
+		TitleLink: story.Link,
+		Fields: []mattermostField{
+			{Short: true, Title: "Author", Value: truncateString(story.Author, 1024)},
+			{Short: true, Title: "Company", Value: truncateString(story.Company, 1024)},
+			{Short: true, Title: "Tag", Value: truncateString(story.Tag, 1024)},
+			{Short: false, Title: "Link", Value: story.Link},
+		},
+	}
+
+	if err := m.post(mattermostPayload{Attachments: []mattermostAttachment{summary}}); err != nil {
+		return fmt.Errorf("failed to send summary: %w", err)
+	}
+
+	chunks := chunkText(story.Description, mattermostMaxContentLength)
+	for i, chunk := range chunks {
+		attachment := mattermostAttachment{
+			Fallback: chunkTitle(i, len(chunks)),
+			Color:    m.Color,
+			Title:    chunkTitle(i, len(chunks)),
+			Text:     chunk,
+		}
+
+		if err := m.post(mattermostPayload{Attachments: []mattermostAttachment{attachment}}); err != nil {
+			return fmt.Errorf("failed to send description chunk: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// SendError sends an error report as a code block
+func (m *Mattermost) SendError(message string) error {
+	return m.post(mattermostPayload{Text: "```\n" + message + "\n```"})
+}
+
+// Health checks the webhook configuration
+func (m *Mattermost) Health() error {
+	if m.WebhookURL == "" {
+		return fmt.Errorf("mattermost webhook configuration missing")
+	}
+	if _, err := url.ParseRequestURI(m.WebhookURL); err != nil {
+		return fmt.Errorf("invalid mattermost webhook URL: %w", err)
+	}
+	return nil
+}
+
+func (m *Mattermost) post(payload mattermostPayload) error {
+	return postJSON(m.client, m.WebhookURL, payload)
+}
+
+func mattermostFromEnv(source string, embedColor int) (base.Notifier, error) {
+	webhookURL := os.Getenv("MATTERMOST_WEBHOOK_URL_" + source)
+	if webhookURL == "" {
+		return nil, nil
+	}
+	return NewMattermost(webhookURL, embedColor), nil
+}
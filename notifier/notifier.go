@@ -0,0 +1,100 @@
+package notifier
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/base"
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/errorhandling"
+)
+
+// builder creates a notifier for a source from environment variables. It
+// returns nil when the notifier is not configured for that source.
+type builder func(source string, embedColor int) (base.Notifier, error)
+
+var builders = []builder{
+	discordFromEnv,
+	slackFromEnv,
+	telegramFromEnv,
+	emailFromEnv,
+	jsonWebhookFromEnv,
+	matrixFromEnv,
+	teamsFromEnv,
+	mattermostFromEnv,
+	pushFromEnv,
+	jsonlFromEnv,
+}
+
+// FromEnv creates every notifier configured for the given source (e.g. "MULA")
+func FromEnv(source string, embedColor int) ([]base.Notifier, error) {
+	source = strings.ToUpper(source)
+
+	var notifiers []base.Notifier
+	for _, build := range builders {
+		n, err := build(source, embedColor)
+		if err != nil {
+			return nil, err
+		}
+		if n != nil {
+			notifiers = append(notifiers, n)
+		}
+	}
+
+	if len(notifiers) == 0 {
+		return nil, errorhandling.NewError(errorhandling.ConfigError, "Missing webhook configuration", nil)
+	}
+	return notifiers, nil
+}
+
+// ErrorReporterFromEnv creates the error reporter from every notifier
+// configured for the ERROR source, e.g. WEBHOOK_ID_ERROR or
+// SLACK_WEBHOOK_URL_ERROR. At least one is required.
+func ErrorReporterFromEnv() (errorhandling.Reporter, error) {
+	var r reporters
+	for _, build := range builders {
+		n, err := build("ERROR", 0)
+		if err != nil {
+			return nil, err
+		}
+		if n != nil {
+			r = append(r, n)
+		}
+	}
+
+	if len(r) == 0 {
+		return nil, errorhandling.NewError(errorhandling.ConfigError, "Missing error notifier configuration", nil)
+	}
+	return r, nil
+}
+
+// reporters sends error reports with SendError of every notifier
+type reporters []base.Notifier
+
+func (r reporters) SendError(message string) error {
+	var errs []error
+	for _, n := range r {
+		if err := n.SendError(message); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", n.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func discordFromEnv(source string, embedColor int) (base.Notifier, error) {
+	webhookID := os.Getenv("WEBHOOK_ID_" + source)
+	webhookToken := os.Getenv("WEBHOOK_TOKEN_" + source)
+	if webhookID == "" || webhookToken == "" {
+		return nil, nil
+	}
+
+	if os.Getenv("MODE") == "DEVELOPMENT" {
+		webhookID = os.Getenv("WEBHOOK_ID_ERROR")
+		webhookToken = os.Getenv("WEBHOOK_TOKEN_ERROR")
+	}
+
+	discord := NewDiscord(webhookID, webhookToken, embedColor)
+	discord.BotToken = os.Getenv("DISCORD_BOT_TOKEN")
+	return discord, nil
+}
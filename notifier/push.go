@@ -0,0 +1,208 @@
+package notifier
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/base"
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/errorhandling"
+)
+
+const pushDefaultMaxLength = 200
+
+// Push flavors
+const (
+	PushNtfy   = "ntfy"
+	PushGotify = "gotify"
+)
+
+// Push sends short phone notifications to an ntfy or Gotify compatible server
+type Push struct {
+	// URL is the topic URL for ntfy (https://ntfy.sh/topic) or the server
+	// URL for Gotify (https://gotify.example.com)
+	URL       string
+	Token     string
+	Flavor    string
+	MaxLength int
+	client    *http.Client
+}
+
+type ntfyMessage struct {
+	Topic    string `json:"topic"`
+	Title    string `json:"title"`
+	Message  string `json:"message"`
+	Priority int    `json:"priority"`
+	Click    string `json:"click,omitempty"`
+}
+
+type gotifyMessage struct {
+	Title    string                 `json:"title"`
+	Message  string                 `json:"message"`
+	Priority int                    `json:"priority"`
+	Extras   map[string]interface{} `json:"extras,omitempty"`
+}
+
+// NewPush creates a push notifier
+func NewPush(pushURL, token, flavor string, maxLength int) *Push {
+	if maxLength <= 0 {
+		maxLength = pushDefaultMaxLength
+	}
+	return &Push{
+		URL:       strings.TrimSuffix(pushURL, "/"),
+		Token:     token,
+		Flavor:    flavor,
+		MaxLength: maxLength,
+		client:    &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (p *Push) Name() string {
+	return "push-" + p.Flavor
+}
+
+// Send sends a short notification titled with the company and tag that
+// opens the story when clicked
+func (p *Push) Send(story *base.Story) error {
+	title := story.Company
+	if story.Tag != "" {
+		title += " · " + story.Tag
+	}
+	return p.push(title, truncateString(story.Description, p.MaxLength), story.Link, pushPriority(story.Tag))
+}
+
+// SendEvent sends a short notification titled with the event
+func (p *Push) SendEvent(event *base.Event) error {
+	story := event.AsStory()
+	return p.push(story.Title, truncateString(story.Description, p.MaxLength), story.Link, pushPriority(story.Tag))
+}
+
+// SendError sends an error report with high priority
+func (p *Push) SendError(message string) error {
+	return p.push("Notifier error", truncateString(message, p.MaxLength), "", 4)
+}
+
+// Health checks the server configuration. Gotify servers are probed via
+// their health endpoint.
+func (p *Push) Health() error {
+	if _, err := url.ParseRequestURI(p.URL); err != nil {
+		return fmt.Errorf("invalid push URL: %w", err)
+	}
+	if p.Flavor != PushGotify {
+		return nil
+	}
+
+	resp, err := p.client.Get(p.URL + "/health")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// push sends a notification. priority uses ntfy's 1 (min) to 5 (max) scale.
+func (p *Push) push(title, message, click string, priority int) error {
+	if p.Flavor == PushGotify {
+		msg := gotifyMessage{
+			Title:    title,
+			Message:  message,
+			Priority: gotifyPriority(priority),
+		}
+		if click != "" {
+			msg.Extras = map[string]interface{}{
+				"client::notification": map[string]interface{}{
+					"click": map[string]string{"url": click},
+				},
+			}
+		}
+		return postJSON(p.client, p.URL+"/message?token="+url.QueryEscape(p.Token), msg)
+	}
+
+	// ntfy only accepts JSON when published to the server root, which is
+	// the parent of the topic URL, also behind a path prefix
+	topicURL, err := url.Parse(p.URL)
+	if err != nil {
+		return err
+	}
+	topic := path.Base(topicURL.Path)
+	rootURL := topicURL.ResolveReference(&url.URL{Path: "./"})
+
+	req, err := newJSONRequest("POST", rootURL.String(), ntfyMessage{
+		Topic:    topic,
+		Title:    title,
+		Message:  message,
+		Priority: priority,
+		Click:    click,
+	})
+	if err != nil {
+		return err
+	}
+	if p.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.Token)
+	}
+	return doRequest(p.client, req)
+}
+
+// pushPriority maps a review tag to a priority, negative reviews are the
+// ones worth interrupting for
+func pushPriority(tag string) int {
+	tag = strings.ToLower(tag)
+	switch {
+	case strings.Contains(tag, "negative"):
+		return 4
+	case strings.Contains(tag, "positive"):
+		return 2
+	default:
+		return 3
+	}
+}
+
+// gotifyPriority converts an ntfy priority to Gotify's 0-10 scale
+func gotifyPriority(priority int) int {
+	switch priority {
+	case 1:
+		return 1
+	case 2:
+		return 3
+	case 4:
+		return 8
+	case 5:
+		return 10
+	default:
+		return 5
+	}
+}
+
+func pushFromEnv(source string, embedColor int) (base.Notifier, error) {
+	pushURL := os.Getenv("PUSH_URL_" + source)
+	if pushURL == "" {
+		return nil, nil
+	}
+
+	flavor := strings.ToLower(os.Getenv("PUSH_FLAVOR"))
+	if flavor == "" {
+		flavor = PushNtfy
+	}
+	if flavor != PushNtfy && flavor != PushGotify {
+		return nil, errorhandling.NewError(errorhandling.ConfigError, "Unknown push flavor: "+flavor, nil)
+	}
+
+	maxLength := 0
+	if value := os.Getenv("PUSH_MAX_LENGTH"); value != "" {
+		var err error
+		if maxLength, err = strconv.Atoi(value); err != nil {
+			return nil, errorhandling.NewError(errorhandling.ConfigError, "Invalid PUSH_MAX_LENGTH", err)
+		}
+	}
+
+	return NewPush(pushURL, os.Getenv("PUSH_TOKEN"), flavor, maxLength), nil
+}
@@ -0,0 +1,257 @@
+package notifier
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/base"
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/errorhandling"
+)
+
+const (
+	// Slack limits the text of a section block to 3000 characters
+	slackMaxContentLength = 3000
+	slackDefaultAPIURL    = "https://slack.com/api"
+)
+
+// Slack sends stories as Block Kit messages to a Slack incoming webhook, or
+// with chat.postMessage when a bot token and channel are set. Only the Web
+// API returns the message timestamps follow-ups are threaded under.
+type Slack struct {
+	WebhookURL string
+	APIURL     string
+	BotToken   string
+	Channel    string
+	client     *http.Client
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type slackBlock struct {
+	Type   string      `json:"type"`
+	Text   *slackText  `json:"text,omitempty"`
+	Fields []slackText `json:"fields,omitempty"`
+	// Elements are the texts of a context block
+	Elements []slackText `json:"elements,omitempty"`
+}
+
+type slackPayload struct {
+	Channel string       `json:"channel,omitempty"`
+	Text    string       `json:"text"`
+	Blocks  []slackBlock `json:"blocks,omitempty"`
+	// ThreadTS posts the message as a reply in the thread of that message
+	ThreadTS string `json:"thread_ts,omitempty"`
+}
+
+type slackResponse struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error"`
+	TS    string `json:"ts"`
+}
+
+// NewSlack creates a Slack notifier posting to an incoming webhook
+func NewSlack(webhookURL string) *Slack {
+	return &Slack{
+		WebhookURL: webhookURL,
+		APIURL:     slackDefaultAPIURL,
+		client:     &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// NewSlackBot creates a Slack notifier posting to a channel as a bot.
+// apiURL defaults to the public Web API when empty.
+func NewSlackBot(apiURL, botToken, channel string) *Slack {
+	if apiURL == "" {
+		apiURL = slackDefaultAPIURL
+	}
+	return &Slack{
+		APIURL:   strings.TrimSuffix(apiURL, "/"),
+		BotToken: botToken,
+		Channel:  channel,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (s *Slack) Name() string {
+	return "slack"
+}
+
+// Send sends the story summary followed by the description in chunks
+func (s *Slack) Send(story *base.Story) error {
+	_, err := s.send(story, "")
+	return err
+}
+
+// SendThreaded sends a story like Send and returns the timestamp of its
+// summary message, empty when posting to a webhook
+func (s *Slack) SendThreaded(story *base.Story) (string, error) {
+	return s.send(story, "")
+}
+
+// Reply sends an event in the thread of the summary message of its story
+func (s *Slack) Reply(ref string, event *base.Event) error {
+	_, err := s.send(event.AsStory(), ref)
+	return err
+}
+
+// send sends the summary and description of a story, in the thread of
+// threadTS unless it is empty, and returns the timestamp of the summary
+func (s *Slack) send(story *base.Story, threadTS string) (string, error) {
+	title := "📢  " + truncateString(story.Title, 150-len("📢  "))
+	summary := slackPayload{
+		Text: title,
+		Blocks: []slackBlock{
+			{Type: "header", Text: &slackText{Type: "plain_text", Text: title}},
+			{Type: "section", Fields: []slackText{
+				{Type: "mrkdwn", Text: "*Author:*\n" + slackEscape(truncateString(story.Author, 1024))},
+				{Type: "mrkdwn", Text: "*Company:*\n" + slackEscape(truncateString(story.Company, 1024))},
+				{Type: "mrkdwn", Text: "*Tag:*\n" + slackEscape(truncateString(story.Tag, 1024))},
+				{Type: "mrkdwn", Text: "*Link:*\n<" + story.Link + ">"},
+			}},
+		},
+	}
+
+	if !story.PublishedAt.IsZero() {
+		summary.Blocks = append(summary.Blocks, slackBlock{Type: "context", Elements: []slackText{
+			{Type: "mrkdwn", Text: fmt.Sprintf("Published <!date^%d^{date_short_pretty} {time}|%s>",
+				story.PublishedAt.Unix(), story.PublishedAt.UTC().Format("2 Jan 2006 15:04 UTC"))},
+		}})
+	}
+
+	summary.ThreadTS = threadTS
+	ts, err := s.post(summary)
+	if err != nil {
+		return "", fmt.Errorf("failed to send summary: %w", err)
+	}
+
+	chunks := slackChunks(story.Description, slackMaxContentLength)
+	for i, chunk := range chunks {
+		chunkHeader := chunkTitle(i, len(chunks))
+		payload := slackPayload{
+			Text: chunkHeader,
+			Blocks: []slackBlock{
+				{Type: "header", Text: &slackText{Type: "plain_text", Text: chunkHeader}},
+				{Type: "section", Text: &slackText{Type: "mrkdwn", Text: chunk}},
+			},
+			ThreadTS: threadTS,
+		}
+
+		if _, err := s.post(payload); err != nil {
+			return "", fmt.Errorf("failed to send description chunk: %w", err)
+		}
+	}
+
+	return ts, nil
+}
+
+// SendError sends an error report as a code block
+func (s *Slack) SendError(message string) error {
+	_, err := s.post(slackPayload{Text: "```" + slackEscape(message) + "```"})
+	return err
+}
+
+// Health checks the bot token with auth.test, or the webhook configuration.
+// Slack incoming webhooks cannot be probed without posting a message.
+func (s *Slack) Health() error {
+	if s.BotToken != "" {
+		if s.Channel == "" {
+			return fmt.Errorf("slack channel missing")
+		}
+		_, err := s.callAPI("auth.test", struct{}{})
+		return err
+	}
+
+	if s.WebhookURL == "" {
+		return fmt.Errorf("slack webhook configuration missing")
+	}
+	if _, err := url.ParseRequestURI(s.WebhookURL); err != nil {
+		return fmt.Errorf("invalid slack webhook URL: %w", err)
+	}
+	return nil
+}
+
+// post sends a message and returns its timestamp, empty when posting to a
+// webhook
+func (s *Slack) post(payload slackPayload) (string, error) {
+	if s.BotToken == "" {
+		return "", postJSON(s.client, s.WebhookURL, payload)
+	}
+
+	payload.Channel = s.Channel
+	result, err := s.callAPI("chat.postMessage", payload)
+	if err != nil {
+		return "", err
+	}
+	return result.TS, nil
+}
+
+// callAPI calls a Web API method, which reports errors in the body
+func (s *Slack) callAPI(method string, payload interface{}) (slackResponse, error) {
+	var result slackResponse
+
+	req, err := newJSONRequest("POST", s.APIURL+"/"+method, payload)
+	if err != nil {
+		return result, err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.BotToken)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return result, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	if !result.OK {
+		return result, fmt.Errorf("slack API error (%d): %s", resp.StatusCode, result.Error)
+	}
+	return result, nil
+}
+
+// slackEscape escapes the control characters of Slack's mrkdwn format
+func slackEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+// slackChunks splits text into escaped chunks that fit a section block.
+// Splitting happens before escaping so an entity such as &amp; is never cut
+// in half; chunks that escaping pushed over the limit are split again.
+func slackChunks(text string, maxLength int) []string {
+	var chunks []string
+	for _, chunk := range chunkText(text, maxLength) {
+		escaped := slackEscape(chunk)
+		if utf8.RuneCountInString(escaped) > slackMaxContentLength {
+			chunks = append(chunks, slackChunks(chunk, maxLength/2)...)
+			continue
+		}
+		chunks = append(chunks, escaped)
+	}
+	return chunks
+}
+
+func slackFromEnv(source string, embedColor int) (base.Notifier, error) {
+	if botToken := os.Getenv("SLACK_BOT_TOKEN_" + source); botToken != "" {
+		channel := os.Getenv("SLACK_CHANNEL_" + source)
+		if channel == "" {
+			return nil, errorhandling.NewError(errorhandling.ConfigError, "Missing SLACK_CHANNEL_"+source, nil)
+		}
+		return NewSlackBot(os.Getenv("SLACK_API_URL"), botToken, channel), nil
+	}
+
+	webhookURL := os.Getenv("SLACK_WEBHOOK_URL_" + source)
+	if webhookURL == "" {
+		return nil, nil
+	}
+	return NewSlack(webhookURL), nil
+}
@@ -0,0 +1,148 @@
+package notifier
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/base"
+)
+
+const teamsMaxContentLength = 4000
+
+// Teams sends stories as Adaptive Cards to a Microsoft Teams webhook
+type Teams struct {
+	WebhookURL string
+	client     *http.Client
+}
+
+type teamsFact struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+}
+
+type teamsElement struct {
+	Type   string      `json:"type"`
+	Text   string      `json:"text,omitempty"`
+	Size   string      `json:"size,omitempty"`
+	Weight string      `json:"weight,omitempty"`
+	Wrap   bool        `json:"wrap,omitempty"`
+	Facts  []teamsFact `json:"facts,omitempty"`
+}
+
+type teamsAction struct {
+	Type  string `json:"type"`
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+type teamsCard struct {
+	Schema  string         `json:"$schema"`
+	Type    string         `json:"type"`
+	Version string         `json:"version"`
+	Body    []teamsElement `json:"body"`
+	Actions []teamsAction  `json:"actions,omitempty"`
+}
+
+type teamsAttachment struct {
+	ContentType string    `json:"contentType"`
+	Content     teamsCard `json:"content"`
+}
+
+type teamsPayload struct {
+	Type        string            `json:"type"`
+	Attachments []teamsAttachment `json:"attachments"`
+}
+
+// NewTeams creates a Microsoft Teams notifier
+func NewTeams(webhookURL string) *Teams {
+	return &Teams{
+		WebhookURL: webhookURL,
+		client:     &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (t *Teams) Name() string {
+	return "teams"
+}
+
+// Send sends the story summary card followed by the description in chunks
+func (t *Teams) Send(story *base.Story) error {
+	summary := teamsCard{
+		Body: []teamsElement{
+			{Type: "TextBlock", Text: "📢  " + truncateString(story.Title, 256), Size: "Large", Weight: "Bolder", Wrap: true},
+			{Type: "FactSet", Facts: []teamsFact{
+				{Title: "Author", Value: truncateString(story.Author, 1024)},
+				{Title: "Company", Value: truncateString(story.Company, 1024)},
+				{Title: "Tag", Value: truncateString(story.Tag, 1024)},
+			}},
+		},
+		Actions: []teamsAction{
+			{Type: "Action.OpenUrl", Title: "Open story", URL: story.Link},
+		},
+	}
+
+	if err := t.post(summary); err != nil {
+		return fmt.Errorf("failed to send summary card: %w", err)
+	}
+
+	chunks := chunkText(story.Description, teamsMaxContentLength)
+	for i, chunk := range chunks {
+		card := teamsCard{
+			Body: []teamsElement{
+				{Type: "TextBlock", Text: chunkTitle(i, len(chunks)), Weight: "Bolder", Wrap: true},
+				{Type: "TextBlock", Text: chunk, Wrap: true},
+			},
+		}
+
+		if err := t.post(card); err != nil {
+			return fmt.Errorf("failed to send description chunk: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// SendError sends an error report as a card
+func (t *Teams) SendError(message string) error {
+	return t.post(teamsCard{
+		Body: []teamsElement{
+			{Type: "TextBlock", Text: "Error", Weight: "Bolder"},
+			{Type: "TextBlock", Text: truncateString(message, teamsMaxContentLength), Wrap: true},
+		},
+	})
+}
+
+// Health checks the webhook configuration
+func (t *Teams) Health() error {
+	if t.WebhookURL == "" {
+		return fmt.Errorf("teams webhook configuration missing")
+	}
+	if _, err := url.ParseRequestURI(t.WebhookURL); err != nil {
+		return fmt.Errorf("invalid teams webhook URL: %w", err)
+	}
+	return nil
+}
+
+func (t *Teams) post(card teamsCard) error {
+	card.Schema = "http://adaptivecards.io/schemas/adaptive-card.json"
+	card.Type = "AdaptiveCard"
+	card.Version = "1.4"
+
+	return postJSON(t.client, t.WebhookURL, teamsPayload{
+		Type: "message",
+		Attachments: []teamsAttachment{
+			{ContentType: "application/vnd.microsoft.card.adaptive", Content: card},
+		},
+	})
+}
+
+func teamsFromEnv(source string, embedColor int) (base.Notifier, error) {
+	webhookURL := os.Getenv("TEAMS_WEBHOOK_URL_" + source)
+	if webhookURL == "" {
+		return nil, nil
+	}
+	return NewTeams(webhookURL), nil
+}
@@ -0,0 +1,206 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/base"
+)
+
+const (
+	telegramDefaultAPIURL = "https://api.telegram.org"
+	// Telegram limits a message to 4096 characters after entity parsing,
+	// leave room for the chunk heading
+	telegramMaxContentLength = 4096 - 64
+)
+
+// Telegram sends stories to a chat via the Telegram Bot API
+type Telegram struct {
+	APIURL   string
+	BotToken string
+	ChatID   string
+	client   *http.Client
+}
+
+type telegramMessage struct {
+	ChatID                string `json:"chat_id"`
+	Text                  string `json:"text"`
+	ParseMode             string `json:"parse_mode,omitempty"`
+	DisableWebPagePreview bool   `json:"disable_web_page_preview"`
+	ReplyToMessageID      int64  `json:"reply_to_message_id,omitempty"`
+	// AllowSendingWithoutReply still sends replies to deleted messages
+	AllowSendingWithoutReply bool `json:"allow_sending_without_reply,omitempty"`
+}
+
+type telegramResponse struct {
+	OK          bool   `json:"ok"`
+	Description string `json:"description"`
+	Result      struct {
+		// MessageID is only set by sendMessage
+		MessageID int64 `json:"message_id"`
+	} `json:"result"`
+}
+
+// NewTelegram creates a Telegram notifier. apiURL defaults to the public
+// Bot API when empty.
+func NewTelegram(apiURL, botToken, chatID string) *Telegram {
+	if apiURL == "" {
+		apiURL = telegramDefaultAPIURL
+	}
+	return &Telegram{
+		APIURL:   strings.TrimSuffix(apiURL, "/"),
+		BotToken: botToken,
+		ChatID:   chatID,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (t *Telegram) Name() string {
+	return "telegram"
+}
+
+// Send sends the story summary followed by the description in chunks
+func (t *Telegram) Send(story *base.Story) error {
+	_, err := t.send(story, 0)
+	return err
+}
+
+// SendThreaded sends a story like Send and returns the ID of its summary
+// message
+func (t *Telegram) SendThreaded(story *base.Story) (string, error) {
+	messageID, err := t.send(story, 0)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(messageID, 10), nil
+}
+
+// Reply sends an event as replies to the summary message of its story
+func (t *Telegram) Reply(ref string, event *base.Event) error {
+	messageID, err := strconv.ParseInt(ref, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid telegram message reference %q: %w", ref, err)
+	}
+	_, err = t.send(event.AsStory(), messageID)
+	return err
+}
+
+// send sends the summary and description of a story, as replies to
+// replyTo unless it is 0, and returns the ID of the summary message
+func (t *Telegram) send(story *base.Story, replyTo int64) (int64, error) {
+	summary := fmt.Sprintf("📢  *%s*\n\n*Author:* %s\n*Company:* %s\n*Tag:* %s\n*Link:* [%s](%s)",
+		telegramEscape(truncateString(story.Title, 256)),
+		telegramEscape(truncateString(story.Author, 1024)),
+		telegramEscape(truncateString(story.Company, 1024)),
+		telegramEscape(truncateString(story.Tag, 1024)),
+		telegramEscape(story.Link),
+		telegramEscapeURL(story.Link))
+
+	summaryID, err := t.sendMessage(summary, "MarkdownV2", replyTo)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send summary: %w", err)
+	}
+
+	// Split before escaping, escape characters do not count towards the limit
+	chunks := chunkText(story.Description, telegramMaxContentLength)
+	for i, chunk := range chunks {
+		text := "*" + telegramEscape(chunkTitle(i, len(chunks))) + "*\n\n" + telegramEscape(chunk)
+		if _, err := t.sendMessage(text, "MarkdownV2", replyTo); err != nil {
+			return 0, fmt.Errorf("failed to send description chunk: %w", err)
+		}
+	}
+
+	return summaryID, nil
+}
+
+// SendError sends an error report as plain text
+func (t *Telegram) SendError(message string) error {
+	_, err := t.sendMessage(truncateString(message, 4096), "", 0)
+	return err
+}
+
+// Health checks the bot token with getMe
+func (t *Telegram) Health() error {
+	if t.BotToken == "" || t.ChatID == "" {
+		return fmt.Errorf("telegram configuration missing")
+	}
+
+	resp, err := t.client.Get(t.APIURL + "/bot" + t.BotToken + "/getMe")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	_, err = decodeTelegramResponse(resp)
+	return err
+}
+
+// sendMessage sends a message, as a reply to replyTo unless it is 0, and
+// returns its ID
+func (t *Telegram) sendMessage(text string, parseMode string, replyTo int64) (int64, error) {
+	body, err := json.Marshal(telegramMessage{
+		ChatID:                   t.ChatID,
+		Text:                     text,
+		ParseMode:                parseMode,
+		DisableWebPagePreview:    true,
+		ReplyToMessageID:         replyTo,
+		AllowSendingWithoutReply: replyTo != 0,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := t.client.Post(t.APIURL+"/bot"+t.BotToken+"/sendMessage", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	result, err := decodeTelegramResponse(resp)
+	if err != nil {
+		return 0, err
+	}
+	return result.Result.MessageID, nil
+}
+
+func decodeTelegramResponse(resp *http.Response) (telegramResponse, error) {
+	var result telegramResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return result, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	if !result.OK {
+		return result, fmt.Errorf("telegram API error (%d): %s", resp.StatusCode, result.Description)
+	}
+	return result, nil
+}
+
+var telegramEscaper = strings.NewReplacer(
+	`\`, `\\`, "_", `\_`, "*", `\*`, "[", `\[`, "]", `\]`, "(", `\(`, ")", `\)`,
+	"~", `\~`, "`", "\\`", ">", `\>`, "#", `\#`, "+", `\+`, "-", `\-`, "=", `\=`,
+	"|", `\|`, "{", `\{`, "}", `\}`, ".", `\.`, "!", `\!`,
+)
+
+// telegramEscape escapes text for Telegram's MarkdownV2 format
+func telegramEscape(s string) string {
+	return telegramEscaper.Replace(s)
+}
+
+// telegramEscapeURL escapes the URL part of a MarkdownV2 inline link
+func telegramEscapeURL(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ")", `\)`).Replace(s)
+}
+
+func telegramFromEnv(source string, embedColor int) (base.Notifier, error) {
+	botToken := os.Getenv("TELEGRAM_BOT_TOKEN_" + source)
+	chatID := os.Getenv("TELEGRAM_CHAT_ID_" + source)
+	if botToken == "" || chatID == "" {
+		return nil, nil
+	}
+	return NewTelegram(os.Getenv("TELEGRAM_API_URL"), botToken, chatID), nil
+}
@@ -0,0 +1,108 @@
+package notifier
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// newTelegramServer returns a Telegram notifier against a fake Bot API
+// recording the messages it receives
+func newTelegramServer(t *testing.T) (*Telegram, *[]telegramMessage) {
+	var messages []telegramMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var message telegramMessage
+		json.NewDecoder(req.Body).Decode(&message)
+		messages = append(messages, message)
+		w.Write([]byte(`{"ok":true,"result":{"message_id":` + strconv.Itoa(len(messages)) + `}}`))
+	}))
+	t.Cleanup(server.Close)
+	return NewTelegram(server.URL, "token", "chat"), &messages
+}
+
+// telegramUnescape removes the MarkdownV2 escapes, leaving the text Telegram
+// shows and counts towards its limit
+var telegramUnescape = regexp.MustCompile(`\\(.)`)
+
+func TestTelegramEscapesMarkdownV2(t *testing.T) {
+	telegram, messages := newTelegramServer(t)
+
+	reserved := "_*[]()~>#+-=|{}.!`\\"
+	story := testStory()
+	story.Title = "Title " + reserved
+	story.Company = "A.B (Ltd)"
+	story.Link = `https://example.com/story/42_(a)\b`
+	story.Description = "Pay -10% + bonus! Really."
+	if err := telegram.Send(story); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	if len(*messages) != 2 {
+		t.Fatalf("sent %d messages, want the summary and one chunk", len(*messages))
+	}
+	summary, chunk := (*messages)[0], (*messages)[1]
+	if summary.ParseMode != "MarkdownV2" || chunk.ParseMode != "MarkdownV2" {
+		t.Errorf("parse modes = %q, %q, want MarkdownV2", summary.ParseMode, chunk.ParseMode)
+	}
+
+	var escaped strings.Builder
+	for _, r := range reserved {
+		escaped.WriteString(`\` + string(r))
+	}
+	for _, want := range []string{
+		"*Title " + escaped.String() + "*",
+		`*Company:* A\.B \(Ltd\)`,
+		// The link text is escaped like any text, the URL only needs ) and \
+		`[https://example\.com/story/42\_\(a\)\\b](https://example.com/story/42_(a\)\\b)`,
+	} {
+		if !strings.Contains(summary.Text, want) {
+			t.Errorf("summary = %q, want it to contain %q", summary.Text, want)
+		}
+	}
+	if !strings.HasSuffix(chunk.Text, `Pay \-10% \+ bonus\! Really\.`) {
+		t.Errorf("chunk = %q, want the escaped description", chunk.Text)
+	}
+}
+
+func TestTelegramSplitsLongDescriptions(t *testing.T) {
+	telegram, messages := newTelegramServer(t)
+
+	var paragraphs []string
+	for i := 0; i < 300; i++ {
+		paragraphs = append(paragraphs, "Paragraph "+strconv.Itoa(i)+". "+strings.TrimSpace(strings.Repeat("Words, words. ", 3)))
+	}
+	story := testStory()
+	story.Description = strings.Join(paragraphs, "\n")
+	if err := telegram.Send(story); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	chunks := (*messages)[1:]
+	if len(chunks) < 3 {
+		t.Fatalf("description sent in %d messages, want it split", len(chunks))
+	}
+
+	var sent []string
+	for i, chunk := range chunks {
+		text := telegramUnescape.ReplaceAllString(chunk.Text, "$1")
+		if length := utf8.RuneCountInString(text); length > 4096 {
+			t.Errorf("chunk %d is %d characters long, more than Telegram allows", i, length)
+		}
+
+		heading := "*Review/Description (Part " + strconv.Itoa(i+1) + ")*\n\n"
+		if !strings.HasPrefix(text, heading) {
+			t.Errorf("chunk %d starts with %q, want %q", i, text[:min(len(text), 40)], heading)
+		}
+		sent = append(sent, strings.TrimPrefix(text, heading))
+	}
+
+	// Split at line breaks, in order
+	if got := strings.Join(sent, "\n"); got != story.Description {
+		t.Error("the chunks do not add up to the description")
+	}
+}
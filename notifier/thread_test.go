@@ -0,0 +1,95 @@
+package notifier
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/base"
+)
+
+func TestTelegramRepliesToTheSummary(t *testing.T) {
+	var messages []telegramMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var message telegramMessage
+		json.NewDecoder(req.Body).Decode(&message)
+		messages = append(messages, message)
+		w.Write([]byte(`{"ok":true,"result":{"message_id":` + strconv.Itoa(100+len(messages)) + `}}`))
+	}))
+	defer server.Close()
+
+	telegram := NewTelegram(server.URL, "token", "chat")
+	ref, err := telegram.SendThreaded(testStory())
+	if err != nil {
+		t.Fatalf("SendThreaded: %v", err)
+	}
+	if ref != "101" {
+		t.Fatalf("ref = %q, want the summary message 101", ref)
+	}
+
+	event := &base.Event{Kind: base.StoryComment, Story: testStory(), Detail: "New comment"}
+	if err := telegram.Reply(ref, event); err != nil {
+		t.Fatalf("Reply: %v", err)
+	}
+	for i, message := range messages {
+		want := int64(0)
+		if i >= 2 {
+			want = 101
+		}
+		if message.ReplyToMessageID != want {
+			t.Errorf("message %d replies to %d, want %d", i, message.ReplyToMessageID, want)
+		}
+	}
+}
+
+func TestSlackBotRepliesInThread(t *testing.T) {
+	var payloads []slackPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/chat.postMessage" || req.Header.Get("Authorization") != "Bearer token" {
+			w.Write([]byte(`{"ok":false,"error":"invalid_auth"}`))
+			return
+		}
+		var payload slackPayload
+		json.NewDecoder(req.Body).Decode(&payload)
+		payloads = append(payloads, payload)
+		w.Write([]byte(`{"ok":true,"ts":"1700000000.00010` + strconv.Itoa(len(payloads)) + `"}`))
+	}))
+	defer server.Close()
+
+	slack := NewSlackBot(server.URL, "token", "C123")
+	ref, err := slack.SendThreaded(testStory())
+	if err != nil {
+		t.Fatalf("SendThreaded: %v", err)
+	}
+	if ref != "1700000000.000101" {
+		t.Fatalf("ref = %q, want the summary timestamp", ref)
+	}
+
+	event := &base.Event{Kind: base.StoryComment, Story: testStory(), Detail: "New comment"}
+	if err := slack.Reply(ref, event); err != nil {
+		t.Fatalf("Reply: %v", err)
+	}
+	for i, payload := range payloads {
+		want := ""
+		if i >= 2 {
+			want = ref
+		}
+		if payload.Channel != "C123" || payload.ThreadTS != want {
+			t.Errorf("message %d posted to %q in thread %q, want C123 in %q", i, payload.Channel, payload.ThreadTS, want)
+		}
+	}
+}
+
+func TestSlackWebhookCannotThread(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	ref, err := NewSlack(server.URL).SendThreaded(testStory())
+	if err != nil || ref != "" {
+		t.Errorf("SendThreaded = %q, %v, want no reference", ref, err)
+	}
+}
@@ -0,0 +1,182 @@
+package notifier
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/base"
+)
+
+// sinkRecorder records every request of a sink and answers with status
+type sinkRecorder struct {
+	mu       sync.Mutex
+	status   int
+	requests []string
+}
+
+func (r *sinkRecorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.requests = append(r.requests, fmt.Sprintf("%s %s %v %s", req.Method, req.URL.Path, req.Header, body))
+	w.WriteHeader(r.status)
+}
+
+func TestWebhookSinks(t *testing.T) {
+	sinks := []struct {
+		name string
+		new  func(url string) base.Notifier
+	}{
+		{"slack", func(url string) base.Notifier { return NewSlack(url) }},
+		{"teams", func(url string) base.Notifier { return NewTeams(url) }},
+		{"mattermost", func(url string) base.Notifier { return NewMattermost(url, 0xFFDFBA) }},
+		{"matrix", func(url string) base.Notifier { return NewMatrix(url, "token", "!room:example.com") }},
+		{"ntfy", func(url string) base.Notifier { return NewPush(url+"/topic", "", "ntfy", 0) }},
+		{"gotify", func(url string) base.Notifier { return NewPush(url, "token", "gotify", 0) }},
+	}
+
+	for _, sink := range sinks {
+		t.Run(sink.name, func(t *testing.T) {
+			recorder := &sinkRecorder{status: http.StatusOK}
+			server := httptest.NewServer(recorder)
+			defer server.Close()
+
+			if err := sink.new(server.URL).Send(testStory()); err != nil {
+				t.Fatalf("Send: %v", err)
+			}
+			if len(recorder.requests) == 0 {
+				t.Fatal("no request sent")
+			}
+			if sent := strings.Join(recorder.requests, "\n"); !strings.Contains(sent, "Company") {
+				t.Errorf("requests do not contain the story:\n%s", sent)
+			}
+
+			recorder.status = http.StatusInternalServerError
+			if err := sink.new(server.URL).Send(testStory()); err == nil {
+				t.Error("Send succeeded on a server error")
+			}
+		})
+	}
+}
+
+func TestPushTitleAndPriority(t *testing.T) {
+	tests := []struct {
+		flavor    string
+		tag       string
+		wantTitle string
+		wantPrio  string
+	}{
+		{PushNtfy, "Negative", "Company · Negative", `"priority":4`},
+		{PushNtfy, "Positive", "Company · Positive", `"priority":2`},
+		{PushNtfy, "Neutral", "Company · Neutral", `"priority":3`},
+		{PushNtfy, "", "Company", `"priority":3`},
+		{PushGotify, "Negative", "Company · Negative", `"priority":8`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.flavor+" "+tt.tag, func(t *testing.T) {
+			recorder := &sinkRecorder{status: http.StatusOK}
+			server := httptest.NewServer(recorder)
+			defer server.Close()
+
+			story := testStory()
+			story.Tag = tt.tag
+			if err := NewPush(server.URL+"/topic", "token", tt.flavor, 0).Send(story); err != nil {
+				t.Fatalf("Send: %v", err)
+			}
+
+			sent := recorder.requests[0]
+			if !strings.Contains(sent, `"title":"`+tt.wantTitle+`"`) || !strings.Contains(sent, tt.wantPrio) {
+				t.Errorf("request = %s, want title %q and %s", sent, tt.wantTitle, tt.wantPrio)
+			}
+			if !strings.Contains(sent, "https://example.com/story/42") {
+				t.Errorf("request = %s, want the story link to open on click", sent)
+			}
+		})
+	}
+}
+
+func TestErrorReporterUsesEveryErrorNotifier(t *testing.T) {
+	slack := &sinkRecorder{status: http.StatusOK}
+	slackServer := httptest.NewServer(slack)
+	defer slackServer.Close()
+	teams := &sinkRecorder{status: http.StatusInternalServerError}
+	teamsServer := httptest.NewServer(teams)
+	defer teamsServer.Close()
+
+	t.Setenv("SLACK_WEBHOOK_URL_ERROR", slackServer.URL)
+	t.Setenv("TEAMS_WEBHOOK_URL_ERROR", teamsServer.URL)
+	t.Setenv("SLACK_WEBHOOK_URL_MULA", "http://127.0.0.1:1/unused")
+	t.Setenv("JSONL_OUTPUT", "")
+
+	reporter, err := ErrorReporterFromEnv()
+	if err != nil {
+		t.Fatalf("ErrorReporterFromEnv: %v", err)
+	}
+
+	// The failing Teams webhook does not keep the report from Slack
+	err = reporter.SendError("scraper failed")
+	if err == nil || !strings.Contains(err.Error(), "teams") {
+		t.Errorf("SendError = %v, want the Teams failure", err)
+	}
+	if len(slack.requests) != 1 || !strings.Contains(slack.requests[0], "scraper failed") {
+		t.Errorf("slack requests = %v, want the report", slack.requests)
+	}
+	if len(teams.requests) == 0 {
+		t.Error("the report was not sent to Teams")
+	}
+}
+
+func TestNtfyPublishesToTheParentOfTheTopic(t *testing.T) {
+	recorder := &sinkRecorder{status: http.StatusOK}
+	server := httptest.NewServer(recorder)
+	defer server.Close()
+
+	// A server behind a path prefix
+	push := NewPush(server.URL+"/ntfy/alerts", "", PushNtfy, 0)
+	event := &base.Event{Kind: base.StoryComment, Story: testStory(), Detail: "Reply"}
+	if err := push.SendEvent(event); err != nil {
+		t.Fatalf("SendEvent: %v", err)
+	}
+
+	if len(recorder.requests) != 1 {
+		t.Fatalf("sent %d requests, want 1", len(recorder.requests))
+	}
+	sent := recorder.requests[0]
+	if !strings.HasPrefix(sent, "POST /ntfy/ ") {
+		t.Errorf("request = %s, want a POST to /ntfy/", sent)
+	}
+	if !strings.Contains(sent, `"topic":"alerts"`) || !strings.Contains(sent, `"title":"💬 New comment: Title"`) {
+		t.Errorf("request = %s, want the topic and the event title", sent)
+	}
+}
+
+func TestErrorNotifierIsOnlyRequiredForErrors(t *testing.T) {
+	for _, name := range []string{"WEBHOOK_ID_", "WEBHOOK_TOKEN_", "SLACK_WEBHOOK_URL_", "SLACK_BOT_TOKEN_", "TELEGRAM_BOT_TOKEN_",
+		"EMAIL_TO_", "JSON_WEBHOOK_URLS_", "MATRIX_ROOM_ID_", "TEAMS_WEBHOOK_URL_", "MATTERMOST_WEBHOOK_URL_", "PUSH_URL_"} {
+		t.Setenv(name+"ERROR", "")
+		t.Setenv(name+"MULA", "")
+	}
+	t.Setenv("JSONL_OUTPUT", "")
+
+	if _, err := ErrorReporterFromEnv(); err == nil {
+		t.Error("ErrorReporterFromEnv accepted a setup without error notifiers")
+	}
+
+	// A Slack-only setup
+	t.Setenv("SLACK_WEBHOOK_URL_MULA", "https://hooks.slack.com/services/mula")
+	t.Setenv("SLACK_WEBHOOK_URL_ERROR", "https://hooks.slack.com/services/error")
+	if notifiers, err := FromEnv("mula", 0); err != nil || len(notifiers) != 1 {
+		t.Errorf("FromEnv = %v, %v, want the Slack notifier", notifiers, err)
+	}
+	if _, err := ErrorReporterFromEnv(); err != nil {
+		t.Errorf("ErrorReporterFromEnv: %v", err)
+	}
+}
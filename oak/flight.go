@@ -0,0 +1,311 @@
+package oak
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// flightStory is a story object of the Next.js RSC flight payload, as Oak
+// serializes its stories
+type flightStory struct {
+	ID          flightID `json:"id"`
+	Title       string   `json:"title"`
+	CompanyName string   `json:"company_name"`
+	ReviewType  string   `json:"review_type"`
+	// Content is the story HTML
+	Content   string     `json:"content"`
+	Upvotes   int        `json:"upvotes"`
+	CreatedAt flightDate `json:"created_at"`
+}
+
+// flightID is a story ID, serialized as a string or a number
+type flightID string
+
+func (id *flightID) UnmarshalJSON(data []byte) error {
+	var value json.Number
+	if err := json.Unmarshal(data, &value); err == nil {
+		*id = flightID(value)
+		return nil
+	}
+
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return err
+	}
+	*id = flightID(text)
+	return nil
+}
+
+// flightDate is a date serialized as "$D<ISO date>"
+type flightDate struct {
+	time.Time
+}
+
+func (d *flightDate) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return err
+	}
+	if text == "" {
+		return nil
+	}
+
+	t, err := time.Parse(time.RFC3339, strings.TrimPrefix(text, "$D"))
+	if err != nil {
+		return err
+	}
+	d.Time = t
+	return nil
+}
+
+// decodeFlight reassembles the flight stream pushed via self.__next_f and
+// returns its rows by ID
+func decodeFlight(doc *goquery.Document) (map[string]interface{}, error) {
+	var stream strings.Builder
+	doc.Find("script").Each(func(i int, s *goquery.Selection) {
+		if chunk, ok := flightChunk(s.Text()); ok {
+			stream.WriteString(chunk)
+		}
+	})
+
+	if stream.Len() == 0 {
+		return nil, errors.New("no flight data found")
+	}
+	return parseFlightRows(stream.String()), nil
+}
+
+// flightChunk extracts the string of a self.__next_f.push([1,"..."]) call.
+// Other chunk types (bootstrap, form state, binary) are skipped.
+func flightChunk(script string) (string, bool) {
+	const prefix = "self.__next_f.push("
+	start := strings.Index(script, prefix)
+	end := strings.LastIndex(script, ")")
+	if start == -1 || end < start+len(prefix) {
+		return "", false
+	}
+
+	var args []json.RawMessage
+	if err := json.Unmarshal([]byte(script[start+len(prefix):end]), &args); err != nil || len(args) < 2 {
+		return "", false
+	}
+
+	var chunkType int
+	if err := json.Unmarshal(args[0], &chunkType); err != nil || chunkType != 1 {
+		return "", false
+	}
+
+	var chunk string
+	if err := json.Unmarshal(args[1], &chunk); err != nil {
+		return "", false
+	}
+	return chunk, true
+}
+
+// parseFlightRows splits the stream into "<hex id>:<payload>" rows. Text rows
+// ("T<hex length>,<text>") are length prefixed and may span lines, every other
+// row ends at a newline. JSON payloads are decoded, rows with other tags
+// (imports, hints, errors) are kept as raw strings.
+func parseFlightRows(stream string) map[string]interface{} {
+	rows := make(map[string]interface{})
+
+	for len(stream) > 0 {
+		colon := strings.IndexByte(stream, ':')
+		newline := strings.IndexByte(stream, '\n')
+		if colon == -1 || (newline != -1 && newline < colon) {
+			// Not a row, skip the line
+			if newline == -1 {
+				break
+			}
+			stream = stream[newline+1:]
+			continue
+		}
+
+		id := stream[:colon]
+		stream = stream[colon+1:]
+
+		if strings.HasPrefix(stream, "T") {
+			if comma := strings.IndexByte(stream, ','); comma != -1 {
+				if length, err := strconv.ParseInt(stream[1:comma], 16, 64); err == nil {
+					end := comma + 1 + int(length)
+					if end > len(stream) {
+						end = len(stream)
+					}
+					rows[id] = stream[comma+1 : end]
+					stream = stream[end:]
+					continue
+				}
+			}
+		}
+
+		payload := stream
+		if newline := strings.IndexByte(stream, '\n'); newline != -1 {
+			payload = stream[:newline]
+			stream = stream[newline+1:]
+		} else {
+			stream = ""
+		}
+
+		if value, err := decodeFlightJSON(payload); err == nil {
+			rows[id] = value
+		} else {
+			rows[id] = payload
+		}
+	}
+
+	return rows
+}
+
+// decodeFlightJSON decodes a row payload, keeping numbers as json.Number so
+// large numeric story IDs do not lose precision through float64
+func decodeFlightJSON(payload string) (interface{}, error) {
+	decoder := json.NewDecoder(strings.NewReader(payload))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	// Trailing data means the payload is not a single JSON value
+	if decoder.More() {
+		return nil, errors.New("trailing data after JSON value")
+	}
+	return value, nil
+}
+
+// flightDecoder resolves "$<id>" references between rows
+type flightDecoder struct {
+	rows      map[string]interface{}
+	resolving map[string]bool
+}
+
+// resolve returns value with row references replaced by the referenced rows
+func (d *flightDecoder) resolve(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		if strings.HasPrefix(v, "$$") {
+			return v[1:]
+		}
+		if !strings.HasPrefix(v, "$") {
+			return v
+		}
+		id := v[1:]
+		row, exists := d.rows[id]
+		if !exists || d.resolving[id] {
+			return v
+		}
+		d.resolving[id] = true
+		resolved := d.resolve(row)
+		delete(d.resolving, id)
+		return resolved
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = d.resolve(item)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			out[key] = d.resolve(item)
+		}
+		return out
+	}
+	return value
+}
+
+// extractFlightStories returns every story object of the flight rows in
+// stream order, without duplicates
+func extractFlightStories(rows map[string]interface{}) []flightStory {
+	ids := make([]string, 0, len(rows))
+	for id := range rows {
+		ids = append(ids, id)
+	}
+	sortFlightIDs(ids)
+
+	decoder := &flightDecoder{rows: rows, resolving: make(map[string]bool)}
+
+	var stories []flightStory
+	seen := make(map[flightID]int)
+	for _, id := range ids {
+		walkFlight(decoder.resolve(rows[id]), func(obj map[string]interface{}) {
+			story, ok := toFlightStory(obj)
+			if !ok {
+				return
+			}
+			if i, exists := seen[story.ID]; exists {
+				// Keep the most complete copy
+				if stories[i].Content == "" && story.Content != "" {
+					stories[i] = story
+				}
+				return
+			}
+			seen[story.ID] = len(stories)
+			stories = append(stories, story)
+		})
+	}
+
+	return stories
+}
+
+// walkFlight calls fn for every object nested in value
+func walkFlight(value interface{}, fn func(map[string]interface{})) {
+	switch v := value.(type) {
+	case []interface{}:
+		for _, item := range v {
+			walkFlight(item, fn)
+		}
+	case map[string]interface{}:
+		fn(v)
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			walkFlight(v[key], fn)
+		}
+	}
+}
+
+// toFlightStory decodes an object holding the story fields. Objects that
+// do not match the story schema are not stories.
+func toFlightStory(obj map[string]interface{}) (flightStory, bool) {
+	if _, ok := obj["company_name"]; !ok {
+		return flightStory{}, false
+	}
+
+	// Numbers were kept as json.Number, they encode back unchanged
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return flightStory{}, false
+	}
+	var story flightStory
+	if err := json.Unmarshal(data, &story); err != nil {
+		return flightStory{}, false
+	}
+
+	if story.ID == "" || story.Title == "" || story.CompanyName == "" {
+		return flightStory{}, false
+	}
+	return story, true
+}
+
+// sortFlightIDs sorts row IDs by their hex value, i.e. stream order
+func sortFlightIDs(ids []string) {
+	value := func(id string) int64 {
+		n, err := strconv.ParseInt(id, 16, 64)
+		if err != nil {
+			return -1
+		}
+		return n
+	}
+	sort.SliceStable(ids, func(i, j int) bool {
+		return value(ids[i]) < value(ids[j])
+	})
+}
@@ -0,0 +1,144 @@
+package oak
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/base"
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/storage"
+)
+
+func TestParseFlightRowsKeepsLargeIDs(t *testing.T) {
+	stream := `1:{"id":9007199254740993,"title":"Title","company_name":"Company","upvotes":12,"content":"<p>Body</p>"}` + "\n" +
+		`2:I["chunk.js"] trailing` + "\n"
+
+	rows := parseFlightRows(stream)
+	if _, ok := rows["2"].(string); !ok {
+		t.Errorf("row 2 = %#v, want the raw payload", rows["2"])
+	}
+
+	stories := extractFlightStories(rows)
+	if len(stories) != 1 {
+		t.Fatalf("found %d stories, want 1", len(stories))
+	}
+	if stories[0].ID != "9007199254740993" {
+		t.Errorf("ID = %q, want 9007199254740993", stories[0].ID)
+	}
+	if stories[0].Upvotes != 12 {
+		t.Errorf("Upvotes = %d, want 12", stories[0].Upvotes)
+	}
+}
+
+func TestFlightChunk(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   string
+		wantOK bool
+	}{
+		{"escaped quotes", `self.__next_f.push([1,"4:{\"title\":\"He said \\\"no\\\"\"}\n"])`, `4:{"title":"He said \"no\""}` + "\n", true},
+		{"escaped markup", `self.__next_f.push([1,"6:T5,\u003cp\u003eHi"])`, "6:T5,<p>Hi", true},
+		{"parentheses in the string", `self.__next_f.push([1,"a:\"(remote) job\""]);`, `a:"(remote) job"`, true},
+		{"bootstrap", `(self.__next_f=self.__next_f||[]).push([0])`, "", false},
+		{"form state", `self.__next_f.push([2,null])`, "", false},
+		{"other script", `window.dataLayer = []`, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := flightChunk(tt.script)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("flightChunk = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+// servePage serves a page fixture to every request
+func servePage(t *testing.T, path string) *base.BaseService {
+	t.Helper()
+	page, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(page)
+	}))
+	t.Cleanup(server.Close)
+	return base.NewBaseService(storage.NewMemoryStore(), server.URL, nil)
+}
+
+// testdata/story.html is a story page in the shape Oak serves it: the flight
+// stream split across pushes mid-row, a text row holding the story HTML, and
+// the story referenced again from the element tree
+func TestStoryPageFixture(t *testing.T) {
+	b := servePage(t, "testdata/story.html")
+
+	links, err := fetchStoryLinks(b, b.BaseURL)
+	if err != nil {
+		t.Fatalf("fetchStoryLinks: %v", err)
+	}
+	// Every story once, although row 4 is referenced twice
+	sort.Strings(links)
+	wantLinks := []string{b.BaseURL + "/story/9007199254740993", b.BaseURL + "/story/a1b2"}
+	if strings.Join(links, " ") != strings.Join(wantLinks, " ") {
+		t.Errorf("links = %v, want %v", links, wantLinks)
+	}
+
+	story, err := fetchAndParseStory(b, b.BaseURL+"/story/a1b2")
+	if err != nil {
+		t.Fatalf("fetchAndParseStory: %v", err)
+	}
+	if story.ID != "a1b2" || story.Title != `Deadlines at "Acme"` || story.Company != "Acme" || story.Tag != "Negative" {
+		t.Errorf("story = %+v, want the fields of row 4", story)
+	}
+	if story.Votes != 7 {
+		t.Errorf("Votes = %d, want 7", story.Votes)
+	}
+	if want := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC); !story.PublishedAt.Equal(want) {
+		t.Errorf("PublishedAt = %v, want %v", story.PublishedAt, want)
+	}
+	wantDescription := "We were told to \"ship it\" by Friday.\n\nNo tests\n\nNo reviews & no docs\n\nI left after 3 months."
+	if story.Description != wantDescription {
+		t.Errorf("Description = %q, want %q", story.Description, wantDescription)
+	}
+
+	// The related story has no content on this page
+	if _, err := fetchAndParseStory(b, b.BaseURL+"/story/9007199254740993"); err == nil {
+		t.Error("parsed a story without content")
+	}
+	if _, err := fetchAndParseStory(b, b.BaseURL+"/story/missing"); !errors.Is(err, base.ErrStoryNotFound) {
+		t.Errorf("missing story error = %v, want ErrStoryNotFound", err)
+	}
+}
+
+func TestFlightStoryVotes(t *testing.T) {
+	tests := []struct {
+		name string
+		row  string
+		want int
+	}{
+		{"upvotes", `{"id":"1","title":"Title","company_name":"Company","upvotes":42}`, 42},
+		{"no upvotes", `{"id":"1","title":"Title","company_name":"Company"}`, 0},
+		// Other counters of the object are not votes
+		{"other counters", `{"id":"1","title":"Title","company_name":"Company","views":900,"comment_count":5}`, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stories := extractFlightStories(parseFlightRows("1:" + tt.row + "\n"))
+			if len(stories) != 1 {
+				t.Fatalf("found %d stories, want 1", len(stories))
+			}
+			if stories[0].Upvotes != tt.want {
+				t.Errorf("Upvotes = %d, want %d", stories[0].Upvotes, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/config"
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/registry"
+)
+
+// runPrune removes old stories from storage, e.g.
+//
+//	deshimula-notifier-unofficial prune -max-age 2160h -dry-run
+//	deshimula-notifier-unofficial prune -source oak -max-count 5000
+func runPrune(args []string) {
+	retention, err := registry.RetentionFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid retention: %v", err)
+	}
+
+	flags := flag.NewFlagSet("prune", flag.ExitOnError)
+	source := flags.String("source", "", "source to prune, defaults to all enabled sources")
+	flags.DurationVar(&retention.MaxAge, "max-age", retention.MaxAge, "prune stories first seen longer ago (0 = no limit, defaults to RETENTION_MAX_AGE)")
+	flags.IntVar(&retention.MaxCount, "max-count", retention.MaxCount, "keep at most this many stories per source (0 = no limit, defaults to RETENTION_MAX_COUNT)")
+	pages := flags.Int("pages", config.PrunePages, "number of list pages whose stories are always kept")
+	delay := flags.Duration("delay", config.BackfillDelay, "pause between two requests")
+	dryRun := flags.Bool("dry-run", false, "only report the stories that would be pruned")
+	flags.Parse(args)
+
+	if !retention.Enabled() {
+		log.Fatalf("Nothing to prune: set -max-age or -max-count")
+	}
+
+	// A dry run only reads the storage
+	if !*dryRun {
+		lock := lockStorage()
+		defer lock.Unlock()
+	}
+
+	if err := registry.Load(); err != nil {
+		log.Fatalf("Failed to load sources: %v", err)
+	}
+
+	names := registry.EnabledNames()
+	if *source != "" {
+		names = []string{*source}
+	}
+
+	opts := registry.PruneOptions{
+		Retention: retention,
+		Pages:     *pages,
+		Delay:     *delay,
+		DryRun:    *dryRun,
+	}
+
+	for _, name := range names {
+		pruned, err := registry.Prune(name, opts)
+		if err != nil {
+			log.Fatalf("Prune of %s failed after %d stories: %v", name, len(pruned), err)
+		}
+
+		verb := "Pruned"
+		if *dryRun {
+			verb = "Would prune"
+			for _, story := range pruned {
+				log.Printf("  %s %s (first seen %s) %s\n", name, story.ID,
+					story.Record.FirstSeen.Format("2006-01-02"), story.Record.Title)
+			}
+		}
+		log.Printf("%s %d %s stories\n", verb, len(pruned), name)
+	}
+}
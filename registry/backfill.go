@@ -0,0 +1,87 @@
+package registry
+
+import (
+	"log"
+	"time"
+
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/errorhandling"
+)
+
+// BackfillOptions controls how far back a backfill walks
+type BackfillOptions struct {
+	// MaxPages is the number of list pages to walk
+	MaxPages int
+	// Count stops the backfill after recording this many new stories, 0
+	// means no limit
+	Count int
+	// Delay is the pause between two story fetches
+	Delay time.Duration
+	// Notify sends the backfilled stories to the notifiers
+	Notify bool
+	// Since stops the backfill at the first story published before it,
+	// zero means no limit. Stories without a publish date are kept.
+	Since time.Time
+}
+
+// Backfill walks the list pages of a source, fetches every story that is
+// not in storage yet and records it. It returns the number of recorded
+// stories. Sources without a PageURL can't be backfilled.
+func Backfill(name string, opts BackfillOptions) (int, error) {
+	s, err := newService(name, false)
+	if err != nil {
+		return 0, err
+	}
+	defer s.Storage.Close()
+
+	// The first page is read by every run anyway
+	if s.def.PageURL == nil {
+		return 0, errorhandling.NewError(errorhandling.ConfigError, "Source "+name+" has no paginated list to backfill", nil)
+	}
+
+	recorded := 0
+	err = s.walkPages(opts.MaxPages, opts.Delay, func(page int, links []string) (bool, error) {
+		log.Printf("Backfilling %s page %d (%d stories)\n", s.def.Name, page, len(links))
+
+		for _, link := range links {
+			if opts.Count > 0 && recorded >= opts.Count {
+				return false, nil
+			}
+
+			storyID := s.StoryID(link)
+			exists, err := s.HasStory(storyID)
+			if err != nil {
+				return false, err
+			}
+			if exists {
+				continue
+			}
+
+			time.Sleep(opts.Delay)
+
+			story, err := s.parseStory(link)
+			if err != nil {
+				errorhandling.HandleError(errorhandling.NewError(errorhandling.ScrapingError, "Failed to fetch story "+link, err))
+				continue
+			}
+
+			// List pages are newest first, everything after is older too
+			if !opts.Since.IsZero() && !story.PublishedAt.IsZero() && story.PublishedAt.Before(opts.Since) {
+				return false, nil
+			}
+
+			var messages map[string]string
+			if opts.Notify {
+				if messages, err = s.Notify(story); err != nil {
+					return false, err
+				}
+			}
+
+			if err := s.SaveStory(storyID, story, messages); err != nil {
+				return false, errorhandling.NewError(errorhandling.StorageError, "Failed to mark story as sent", err)
+			}
+			recorded++
+		}
+		return true, nil
+	})
+	return recorded, err
+}
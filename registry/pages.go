@@ -0,0 +1,45 @@
+package registry
+
+import (
+	"log"
+	"strings"
+	"time"
+)
+
+// walkPages calls visit with the story links of the list pages 1 to
+// maxPages, pausing delay between two pages. The walk stops at an empty
+// page, at a page repeating the previous one and once visit returns false.
+// Sources without a PageURL only have the first page.
+func (s *service) walkPages(maxPages int, delay time.Duration, visit func(page int, links []string) (bool, error)) error {
+	var previous string
+	for page := 1; page <= maxPages; page++ {
+		if s.def.PageURL == nil && page > 1 {
+			break
+		}
+		if page > 1 {
+			time.Sleep(delay)
+		}
+
+		links, err := s.def.FetchLinks(s.BaseService, s.def.ListURL(page))
+		if err != nil {
+			return err
+		}
+
+		if len(links) == 0 {
+			break
+		}
+		// Sites ignoring the page parameter keep returning the first page
+		current := strings.Join(links, "\n")
+		if current == previous {
+			log.Printf("%s list page %d repeats page %d, the site ignores the page parameter\n", s.def.Name, page, page-1)
+			break
+		}
+		previous = current
+
+		more, err := visit(page, links)
+		if err != nil || !more {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package registry
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/base"
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/storage"
+)
+
+func TestWalkPages(t *testing.T) {
+	// Pages 1 and 2 list stories, page 3 repeats page 2, page 4 is empty
+	pages := map[string][]string{
+		"https://example.com":         {"a", "b"},
+		"https://example.com/?page=2": {"c"},
+		"https://example.com/?page=3": {"c"},
+	}
+	fetchLinks := func(b *base.BaseService, listURL string) ([]string, error) {
+		return pages[listURL], nil
+	}
+
+	tests := []struct {
+		name     string
+		pageURL  func(baseURL string, page int) string
+		maxPages int
+		stopAt   int
+		want     string
+	}{
+		{"stops at a repeated page", PageQuery, 10, 0, "1:a,b 2:c"},
+		{"max pages", PageQuery, 1, 0, "1:a,b"},
+		{"visit stops", PageQuery, 10, 1, "1:a,b"},
+		{"first page only", nil, 10, 0, "1:a,b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &service{
+				BaseService: base.NewBaseService(storage.NewMemoryStore(), "https://example.com", nil),
+				def:         Definition{Name: "test", BaseURL: "https://example.com", FetchLinks: fetchLinks, PageURL: tt.pageURL},
+			}
+
+			var visited []string
+			err := s.walkPages(tt.maxPages, 0, func(page int, links []string) (bool, error) {
+				visited = append(visited, fmt.Sprintf("%d:%s", page, strings.Join(links, ",")))
+				return page != tt.stopAt, nil
+			})
+			if err != nil {
+				t.Fatalf("walkPages: %v", err)
+			}
+			if got := strings.Join(visited, " "); got != tt.want {
+				t.Errorf("visited %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
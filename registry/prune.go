@@ -0,0 +1,55 @@
+package registry
+
+import (
+	"time"
+
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/base"
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/errorhandling"
+)
+
+// PruneOptions controls a prune run
+type PruneOptions struct {
+	Retention base.Retention
+	// Pages is the number of list pages whose stories are kept
+	Pages int
+	// Delay is the pause between two list page fetches
+	Delay time.Duration
+	// DryRun only reports the stories that would be pruned, the storage is
+	// opened read-only
+	DryRun bool
+}
+
+// Prune removes the stored stories of a source the retention does not keep.
+// The stories on the first opts.Pages list pages are always kept; if the
+// list cannot be fetched nothing is pruned.
+func Prune(name string, opts PruneOptions) ([]base.PrunedStory, error) {
+	s, err := newService(name, opts.DryRun)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Storage.Close()
+
+	links, err := s.listedLinks(opts.Pages, opts.Delay)
+	if err != nil {
+		return nil, err
+	}
+	if len(links) == 0 {
+		return nil, errorhandling.NewError(errorhandling.ScrapingError, "No stories listed for "+name+", refusing to prune", nil)
+	}
+
+	return s.Prune(opts.Retention, links, opts.DryRun)
+}
+
+// listedLinks returns the story links of the first pages list pages, the
+// stories a prune has to keep
+func (s *service) listedLinks(pages int, delay time.Duration) ([]string, error) {
+	var links []string
+	err := s.walkPages(pages, delay, func(page int, pageLinks []string) (bool, error) {
+		links = append(links, pageLinks...)
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return links, nil
+}
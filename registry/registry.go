@@ -0,0 +1,303 @@
+package registry
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/base"
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/config"
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/errorhandling"
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/feed"
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/interfacer"
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/notifier"
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/storage"
+)
+
+// Definition describes a story source
+type Definition struct {
+	Name        string
+	BaseURL     string
+	StorageFile string
+	EmbedColor  int
+	// FetchLinks returns the story links listed on a list page
+	FetchLinks func(b *base.BaseService, listURL string) ([]string, error)
+	// PageURL optionally returns the URL of the n-th list page (starting
+	// at 1), sources without it only have the base URL as list page
+	PageURL func(baseURL string, page int) string
+	// ParseStory fetches and parses a single story
+	ParseStory func(b *base.BaseService, link string) (*base.Story, error)
+	// StoryID optionally extracts the story ID from a link, see
+	// base.BaseService.IDFromLink
+	StoryID func(link string) string
+	// WatchComments is set by sources whose ParseStory fills the comments
+	WatchComments bool
+	// SkipRemovalCheck is set by sources whose stories cannot be told
+	// removed from no longer listed (feeds only list their latest entries)
+	SkipRemovalCheck bool
+}
+
+// ListURL returns the URL of the n-th list page
+func (d Definition) ListURL(page int) string {
+	if d.PageURL == nil {
+		return d.BaseURL
+	}
+	return d.PageURL(d.BaseURL, page)
+}
+
+// PageQuery is a Definition.PageURL for sites that take the list page as a
+// ?page= query parameter
+func PageQuery(baseURL string, page int) string {
+	if page <= 1 {
+		return baseURL
+	}
+	return fmt.Sprintf("%s/?page=%d", baseURL, page)
+}
+
+var (
+	definitions = make(map[string]Definition)
+	loaders     []func() error
+	mu          sync.RWMutex
+)
+
+// AddLoader adds a function that registers sources at runtime (e.g. from a
+// configuration file). Loaders run before the enabled sources are created.
+func AddLoader(loader func() error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	loaders = append(loaders, loader)
+}
+
+// Load runs every loader
+func Load() error {
+	mu.RLock()
+	pending := append([]func() error(nil), loaders...)
+	mu.RUnlock()
+
+	for _, loader := range pending {
+		if err := loader(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Register adds a source. Registering a name twice replaces the earlier
+// definition.
+func Register(def Definition) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	definitions[strings.ToLower(def.Name)] = def
+}
+
+// Names returns the names of all registered sources
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	names := make([]string, 0, len(definitions))
+	for name := range definitions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Lookup returns the definition of a registered source
+func Lookup(name string) (Definition, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	def, exists := definitions[strings.ToLower(name)]
+	return def, exists
+}
+
+// newService creates the service of a source, readOnly opens its storage
+// with storage.OpenReadOnly
+func newService(name string, readOnly bool) (*service, error) {
+	def, exists := Lookup(name)
+	if !exists {
+		return nil, errorhandling.NewError(errorhandling.ConfigError, "Unknown source: "+name, nil)
+	}
+
+	notifiers, err := notifier.FromEnv(def.Name, def.EmbedColor)
+	if err != nil {
+		return nil, err
+	}
+
+	backend := os.Getenv("STORAGE_BACKEND")
+	// Every restart would send the stories on the list page again
+	if backend == storage.MemoryBackend {
+		return nil, errorhandling.NewError(errorhandling.ConfigError, "STORAGE_BACKEND=memory keeps no stories across restarts and is only meant for tests", nil)
+	}
+
+	open := storage.Open
+	if readOnly {
+		open = storage.OpenReadOnly
+	}
+	store, err := open(backend, config.StorageDir, def.StorageFile, def.Name)
+	if err != nil {
+		return nil, errorhandling.NewError(errorhandling.ConfigError, "Failed to initialize storage", err)
+	}
+
+	baseService := base.NewBaseService(store, def.BaseURL, notifiers)
+	baseService.IDFromLink = def.StoryID
+	baseService.Source = def.Name
+
+	if def.WatchComments {
+		if baseService.CommentWatchWindow, err = durationFromEnv("COMMENT_WATCH_WINDOW", config.CommentWatchWindow); err != nil {
+			return nil, err
+		}
+	}
+
+	if value := os.Getenv("TRENDING_VELOCITY"); value != "" {
+		if baseService.TrendingVelocity, err = strconv.ParseFloat(value, 64); err != nil {
+			return nil, errorhandling.NewError(errorhandling.ConfigError, "Invalid TRENDING_VELOCITY", err)
+		}
+	}
+	if baseService.TrendingWindow, err = durationFromEnv("TRENDING_WINDOW", config.TrendingWindow); err != nil {
+		return nil, err
+	}
+
+	if baseService.Retention, err = RetentionFromEnv(); err != nil {
+		return nil, err
+	}
+
+	return &service{
+		BaseService: baseService,
+		def:         def,
+	}, nil
+}
+
+// RetentionFromEnv returns the retention set by RETENTION_MAX_AGE (a
+// duration) and RETENTION_MAX_COUNT, both unset means keep everything
+func RetentionFromEnv() (base.Retention, error) {
+	var retention base.Retention
+
+	maxAge, err := durationFromEnv("RETENTION_MAX_AGE", 0)
+	if err != nil {
+		return retention, err
+	}
+	retention.MaxAge = maxAge
+
+	if value := os.Getenv("RETENTION_MAX_COUNT"); value != "" {
+		if retention.MaxCount, err = strconv.Atoi(value); err != nil {
+			return retention, errorhandling.NewError(errorhandling.ConfigError, "Invalid RETENTION_MAX_COUNT", err)
+		}
+	}
+	return retention, nil
+}
+
+// durationFromEnv parses a duration variable, returning def when it is unset
+func durationFromEnv(name string, def time.Duration) (time.Duration, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, errorhandling.NewError(errorhandling.ConfigError, "Invalid "+name, err)
+	}
+	return d, nil
+}
+
+// EnabledNames returns the sources listed in SOURCES (comma separated),
+// or every registered source when it is not set
+func EnabledNames() []string {
+	value := os.Getenv("SOURCES")
+	if value == "" {
+		return Names()
+	}
+
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, strings.ToLower(name))
+		}
+	}
+	return names
+}
+
+// Enabled runs the loaders and creates the services of all enabled sources.
+// Their stored stories are published in the shared feed.
+func Enabled() ([]interfacer.Service, error) {
+	if err := Load(); err != nil {
+		return nil, err
+	}
+
+	var services []interfacer.Service
+	for _, name := range EnabledNames() {
+		svc, err := newService(name, false)
+		if err != nil {
+			return nil, err
+		}
+		svc.Storage = feed.Shared().AddSource(svc.def.Name, svc.Storage)
+		services = append(services, svc)
+	}
+
+	if len(services) == 0 {
+		return nil, errorhandling.NewError(errorhandling.ConfigError, "No sources enabled", nil)
+	}
+	return services, nil
+}
+
+// service runs a source definition on top of the base service
+type service struct {
+	*base.BaseService
+	def Definition
+}
+
+func (s *service) Name() string {
+	return s.def.Name
+}
+
+func (s *service) FetchAndProcessStories() error {
+	links, err := s.fetchLinks()
+	if err != nil {
+		return err
+	}
+
+	fetched := func() ([]string, error) { return links, nil }
+	if err := s.BaseService.FetchAndProcessStories(fetched, s.processStory); err != nil {
+		return err
+	}
+
+	s.RecheckStories(links, s.parseStory)
+	if !s.def.SkipRemovalCheck {
+		s.CheckRemovedStories(links, s.parseStory)
+	}
+	s.CheckComments(s.parseStory)
+	s.PruneStories(func() ([]string, error) {
+		return s.listedLinks(config.PrunePages, config.BackfillDelay)
+	})
+	return nil
+}
+
+func (s *service) fetchLinks() ([]string, error) {
+	return s.def.FetchLinks(s.BaseService, s.def.ListURL(1))
+}
+
+func (s *service) processStory(link string) error {
+	return s.ProcessStory(link, s.parseStory)
+}
+
+// parseStory parses a story and fills the fields every source shares
+func (s *service) parseStory(link string) (*base.Story, error) {
+	story, err := s.def.ParseStory(s.BaseService, link)
+	if err != nil {
+		return nil, err
+	}
+
+	if story.ID == "" {
+		story.ID = s.StoryID(link)
+	}
+	story.Source = s.def.Name
+	story.ScrapedAt = time.Now().UTC()
+	return story, nil
+}
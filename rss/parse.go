@@ -0,0 +1,211 @@
+package rss
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/base"
+)
+
+// feedDocument decodes both RSS 2.0 (<rss><channel>) and Atom (<feed>)
+type feedDocument struct {
+	XMLName xml.Name
+	// RSS
+	Channel struct {
+		Title string    `xml:"title"`
+		Items []rssItem `xml:"item"`
+	} `xml:"channel"`
+	// Atom
+	Title   string      `xml:"title"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        string   `xml:"guid"`
+	Description string   `xml:"description"`
+	Content     string   `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	Author      string   `xml:"author"`
+	Creator     string   `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Categories  []string `xml:"category"`
+	PubDate     string   `xml:"pubDate"`
+}
+
+type atomEntry struct {
+	Title string `xml:"title"`
+	Links []struct {
+		Href string `xml:"href,attr"`
+		Rel  string `xml:"rel,attr"`
+	} `xml:"link"`
+	ID      string `xml:"id"`
+	Summary string `xml:"summary"`
+	Content string `xml:"content"`
+	Author  struct {
+		Name string `xml:"name"`
+	} `xml:"author"`
+	Categories []struct {
+		Term string `xml:"term,attr"`
+	} `xml:"category"`
+	Published string `xml:"published"`
+	Updated   string `xml:"updated"`
+}
+
+// feedDateLayouts are the date formats used by RSS (RFC 822) and Atom
+// (RFC 3339) feeds in the wild
+var feedDateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	time.RFC3339,
+}
+
+// parseFeed maps the entries of a feed to stories, newest first
+func parseFeed(r io.Reader, sc SourceConfig) ([]*base.Story, error) {
+	var doc feedDocument
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	var stories []*base.Story
+	switch doc.XMLName.Local {
+	case "rss":
+		company := companyName(sc, doc.Channel.Title)
+		for _, item := range doc.Channel.Items {
+			link := strings.TrimSpace(item.Link)
+			if link == "" {
+				link = strings.TrimSpace(item.GUID)
+			}
+			author := item.Author
+			if author == "" {
+				author = item.Creator
+			}
+			content := item.Content
+			if content == "" {
+				content = item.Description
+			}
+			stories = append(stories, newStory(sc, company, link, item.Title, author, content,
+				item.Categories, parseDate(item.PubDate)))
+		}
+	case "feed":
+		company := companyName(sc, doc.Title)
+		for _, entry := range doc.Entries {
+			link := strings.TrimSpace(entry.ID)
+			for _, l := range entry.Links {
+				if l.Rel == "" || l.Rel == "alternate" {
+					link = strings.TrimSpace(l.Href)
+					break
+				}
+			}
+			content := entry.Content
+			if content == "" {
+				content = entry.Summary
+			}
+			var categories []string
+			for _, c := range entry.Categories {
+				categories = append(categories, c.Term)
+			}
+			published := entry.Published
+			if published == "" {
+				published = entry.Updated
+			}
+			stories = append(stories, newStory(sc, company, link, entry.Title, entry.Author.Name, content,
+				categories, parseDate(published)))
+		}
+	default:
+		return nil, errors.New("not an RSS or Atom feed: <" + doc.XMLName.Local + ">")
+	}
+
+	// Most feeds are sorted already, entries without a date go last
+	sort.SliceStable(stories, func(i, j int) bool {
+		return stories[i].PublishedAt.After(stories[j].PublishedAt)
+	})
+
+	valid := stories[:0]
+	for _, story := range stories {
+		if story.Link != "" {
+			valid = append(valid, story)
+		}
+	}
+	return valid, nil
+}
+
+func newStory(sc SourceConfig, company, link, title, author, content string, categories []string, published time.Time) *base.Story {
+	story := &base.Story{
+		ID:          storyID(link),
+		Link:        link,
+		Title:       strings.TrimSpace(title),
+		Author:      strings.TrimSpace(author),
+		Company:     company,
+		Tag:         sc.Tag,
+		Description: htmlText(content),
+		PublishedAt: published,
+	}
+	if len(categories) > 0 {
+		story.Tag = strings.TrimSpace(categories[0])
+	}
+	// Title-only entries would fail validation
+	if story.Description == "" {
+		story.Description = story.Title
+	}
+	return story
+}
+
+func companyName(sc SourceConfig, feedTitle string) string {
+	if sc.Company != "" {
+		return sc.Company
+	}
+	if title := strings.TrimSpace(feedTitle); title != "" {
+		return title
+	}
+	return sc.Name
+}
+
+// htmlText converts entry HTML to the description markup used by the
+// other sources
+func htmlText(content string) string {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(content))
+	if err != nil {
+		return strings.TrimSpace(content)
+	}
+
+	blocks := doc.Find("p, h1, h2, h3, h4, h5, h6, li")
+	if blocks.Length() == 0 {
+		return strings.TrimSpace(doc.Text())
+	}
+
+	var description strings.Builder
+	blocks.Each(func(i int, s *goquery.Selection) {
+		text := strings.TrimSpace(s.Text())
+		if text == "" {
+			return
+		}
+		switch {
+		case s.Is("h1, h2, h3"):
+			description.WriteString("\n### " + text + " ###\n")
+		case s.Is("h4, h5, h6"):
+			description.WriteString("\n## " + text + " ##\n")
+		case s.Is("li"):
+			description.WriteString("- " + text + "\n")
+		default:
+			description.WriteString(text + "\n")
+		}
+	})
+	return strings.TrimSpace(description.String())
+}
+
+func parseDate(value string) time.Time {
+	value = strings.TrimSpace(value)
+	for _, layout := range feedDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
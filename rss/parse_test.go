@@ -0,0 +1,147 @@
+package rss
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const rssFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:content="http://purl.org/rss/1.0/modules/content/" xmlns:dc="http://purl.org/dc/elements/1.1/">
+<channel>
+	<title>Engineering Blog</title>
+	<item>
+		<title>Older post</title>
+		<link>https://example.com/older</link>
+		<dc:creator>Jane</dc:creator>
+		<category>Culture</category>
+		<description>Plain summary</description>
+		<pubDate>Fri, 1 Mar 2024 10:00:00 GMT</pubDate>
+	</item>
+	<item>
+		<title> Newer post </title>
+		<guid>https://example.com/newer</guid>
+		<author>john@example.com</author>
+		<description>Ignored summary</description>
+		<content:encoded><![CDATA[<h2>Intro</h2><p>First &amp; foremost</p><ul><li>one</li><li>two</li></ul>]]></content:encoded>
+		<pubDate>Sat, 02 Mar 2024 10:00:00 +0600</pubDate>
+	</item>
+	<item>
+		<title>No link</title>
+	</item>
+</channel>
+</rss>`
+
+const atomFeed = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+	<title>Jobs</title>
+	<entry>
+		<title>Updated only</title>
+		<id>urn:uuid:1</id>
+		<link rel="self" href="https://example.com/self/1"/>
+		<link href="https://example.com/jobs/1"/>
+		<updated>2024-03-01T10:00:00Z</updated>
+		<summary>Summary only</summary>
+	</entry>
+	<entry>
+		<title>Published</title>
+		<id>https://example.com/jobs/2</id>
+		<author><name>Recruiter</name></author>
+		<category term="Backend"/>
+		<published>2024-03-02T10:00:00+06:00</published>
+		<updated>2024-03-05T10:00:00Z</updated>
+		<summary>Ignored summary</summary>
+		<content type="html">&lt;p&gt;Body&lt;/p&gt;</content>
+	</entry>
+	<entry>
+		<title>Undated</title>
+		<link rel="alternate" href="https://example.com/jobs/3"/>
+	</entry>
+</feed>`
+
+func TestParseFeedRSS(t *testing.T) {
+	stories, err := parseFeed(strings.NewReader(rssFeed), SourceConfig{Name: "blog", Tag: "Blog"})
+	if err != nil {
+		t.Fatalf("parseFeed: %v", err)
+	}
+	if len(stories) != 2 {
+		t.Fatalf("got %d stories, want 2 (the entry without a link is dropped)", len(stories))
+	}
+
+	newer, older := stories[0], stories[1]
+	if newer.Link != "https://example.com/newer" || newer.Title != "Newer post" || newer.Author != "john@example.com" {
+		t.Errorf("newer = %+v, want the guid as link, a trimmed title and the author", newer)
+	}
+	if want := "### Intro ###\nFirst & foremost\n- one\n- two"; newer.Description != want {
+		t.Errorf("newer description = %q, want %q", newer.Description, want)
+	}
+	if newer.Company != "Engineering Blog" || newer.Tag != "Blog" {
+		t.Errorf("newer company, tag = %q, %q, want the feed title and the configured tag", newer.Company, newer.Tag)
+	}
+	if newer.ID != storyID(newer.Link) {
+		t.Errorf("newer ID = %q, want the hash of the link", newer.ID)
+	}
+
+	if older.Author != "Jane" || older.Tag != "Culture" || older.Description != "Plain summary" {
+		t.Errorf("older = %+v, want the dc:creator, the category and the description", older)
+	}
+}
+
+func TestParseFeedAtom(t *testing.T) {
+	stories, err := parseFeed(strings.NewReader(atomFeed), SourceConfig{Name: "jobs", Company: "Acme"})
+	if err != nil {
+		t.Fatalf("parseFeed: %v", err)
+	}
+	if len(stories) != 3 {
+		t.Fatalf("got %d stories, want 3", len(stories))
+	}
+
+	// Sorted by publish date, the updated date stands in for a missing one
+	wantLinks := []string{"https://example.com/jobs/2", "https://example.com/jobs/1", "https://example.com/jobs/3"}
+	for i, want := range wantLinks {
+		if stories[i].Link != want {
+			t.Errorf("story %d link = %q, want %q", i, stories[i].Link, want)
+		}
+	}
+
+	published := stories[0]
+	if published.Author != "Recruiter" || published.Tag != "Backend" || published.Description != "Body" {
+		t.Errorf("published = %+v, want the author, the category term and the content", published)
+	}
+	if published.Company != "Acme" {
+		t.Errorf("company = %q, want the configured company", published.Company)
+	}
+	if stories[1].Description != "Summary only" {
+		t.Errorf("description = %q, want the summary", stories[1].Description)
+	}
+	// Title-only entries use the title as description
+	if stories[2].Description != "Undated" || !stories[2].PublishedAt.IsZero() {
+		t.Errorf("undated = %+v, want the title as description and no date", stories[2])
+	}
+}
+
+func TestParseFeedRejectsOtherDocuments(t *testing.T) {
+	if _, err := parseFeed(strings.NewReader(`<html><body></body></html>`), SourceConfig{}); err == nil {
+		t.Error("parseFeed accepted an HTML page")
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{"Fri, 01 Mar 2024 10:00:00 +0600", time.Date(2024, 3, 1, 4, 0, 0, 0, time.UTC)},
+		{"Fri, 1 Mar 2024 10:00:00 -0700", time.Date(2024, 3, 1, 17, 0, 0, 0, time.UTC)},
+		{"Fri, 01 Mar 2024 10:00:00 GMT", time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)},
+		{" 2024-03-01T10:00:00+06:00 ", time.Date(2024, 3, 1, 4, 0, 0, 0, time.UTC)},
+		{"yesterday", time.Time{}},
+		{"", time.Time{}},
+	}
+
+	for _, tt := range tests {
+		if got := parseDate(tt.value); !got.Equal(tt.want) {
+			t.Errorf("parseDate(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+// Package rss registers story sources that read RSS 2.0 or Atom feeds
+package rss
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/base"
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/errorhandling"
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/registry"
+)
+
+// SourceConfig describes a feed read as a story source
+type SourceConfig struct {
+	Name        string `json:"name"`
+	URL         string `json:"url"`
+	StorageFile string `json:"storage_file,omitempty"`
+	// EmbedColor is a hex color such as "FFDFBA"
+	EmbedColor string `json:"embed_color,omitempty"`
+	// Company is shown for every entry, defaults to the feed title
+	Company string `json:"company,omitempty"`
+	// Tag is shown for entries without a category
+	Tag string `json:"tag,omitempty"`
+}
+
+// Config is the content of the feed sources file
+type Config struct {
+	Sources []SourceConfig `json:"sources"`
+}
+
+func init() {
+	registry.AddLoader(func() error {
+		path := os.Getenv("FEED_SOURCES_FILE")
+		if path == "" {
+			return nil
+		}
+		return Register(path)
+	})
+}
+
+// Register registers every feed of the config file
+func Register(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return errorhandling.NewError(errorhandling.ConfigError, "Failed to read feed sources file", err)
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return errorhandling.NewError(errorhandling.ConfigError, "Failed to parse feed sources file", err)
+	}
+
+	for _, sc := range cfg.Sources {
+		if sc.Name == "" || sc.URL == "" {
+			return errorhandling.NewError(errorhandling.ConfigError,
+				fmt.Sprintf("Feed source %q is missing name or url", sc.Name), nil)
+		}
+
+		color, err := strconv.ParseInt(strings.TrimPrefix(sc.EmbedColor, "#"), 16, 32)
+		if err != nil && sc.EmbedColor != "" {
+			return errorhandling.NewError(errorhandling.ConfigError, "Invalid embed color for source "+sc.Name, err)
+		}
+
+		storageFile := sc.StorageFile
+		if storageFile == "" {
+			storageFile = strings.ToLower(sc.Name) + "_sent_stories.json"
+		}
+
+		src := &source{
+			config:  sc,
+			entries: make(map[string]*base.Story),
+		}
+
+		registry.Register(registry.Definition{
+			Name:        sc.Name,
+			BaseURL:     sc.URL,
+			StorageFile: storageFile,
+			EmbedColor:  int(color),
+			FetchLinks:  src.fetchStoryLinks,
+			ParseStory:  src.fetchAndParseStory,
+			StoryID:     storyID,
+			// Entries drop out of a feed as new ones are added
+			SkipRemovalCheck: true,
+		})
+	}
+
+	return nil
+}
+
+// source keeps the entries of the last fetch, so stories are parsed from
+// the feed instead of fetching every linked page
+type source struct {
+	config  SourceConfig
+	entries map[string]*base.Story
+	mu      sync.Mutex
+}
+
+// storyID hashes the entry link, feed links are arbitrary URLs
+func storyID(link string) string {
+	sum := sha256.Sum256([]byte(link))
+	return hex.EncodeToString(sum[:8])
+}
+
+func (s *source) fetchStoryLinks(b *base.BaseService, listURL string) ([]string, error) {
+	stories, err := s.fetch(b, listURL)
+	if err != nil {
+		return nil, err
+	}
+
+	links := make([]string, 0, len(stories))
+	for _, story := range stories {
+		links = append(links, story.Link)
+	}
+	return links, nil
+}
+
+func (s *source) fetchAndParseStory(b *base.BaseService, link string) (*base.Story, error) {
+	if story := s.entry(link); story != nil {
+		return story, nil
+	}
+
+	// Re-checks may ask for entries of an older fetch
+	if _, err := s.fetch(b, s.config.URL); err != nil {
+		return nil, err
+	}
+	if story := s.entry(link); story != nil {
+		return story, nil
+	}
+
+	// Feeds only list their latest entries, an entry that dropped out is
+	// not removed, so report what was stored when it was sent. Entries only
+	// marked as seen have nothing stored, which is unknown rather than gone.
+	record, exists, err := b.Storage.Get(storyID(link))
+	if err != nil {
+		return nil, err
+	}
+	if !exists || record.Hash == "" {
+		return nil, fmt.Errorf("entry %s is no longer in the feed", link)
+	}
+	return &base.Story{
+		Link:        link,
+		Title:       record.Title,
+		Company:     record.Company,
+		Tag:         record.Tag,
+		Description: record.Description,
+		PublishedAt: record.PublishedAt,
+	}, nil
+}
+
+// entry returns a copy of a cached entry
+func (s *source) entry(link string) *base.Story {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	story, exists := s.entries[link]
+	if !exists {
+		return nil
+	}
+	entry := *story
+	return &entry
+}
+
+// fetch reads the feed, newest entries first, and caches its entries
+func (s *source) fetch(b *base.BaseService, url string) ([]*base.Story, error) {
+	resp, err := b.Fetch(url)
+	if err != nil {
+		return nil, errorhandling.NewError(errorhandling.NetworkError, "Failed to fetch feed", err)
+	}
+	defer resp.Body.Close()
+
+	stories, err := parseFeed(resp.Body, s.config)
+	if err != nil {
+		return nil, errorhandling.NewError(errorhandling.ParseError, "Failed to parse feed", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries = make(map[string]*base.Story, len(stories))
+	for _, story := range stories {
+		s.entries[story.Link] = story
+	}
+	return stories, nil
+}
@@ -0,0 +1,324 @@
+package selector
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/base"
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/errorhandling"
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/registry"
+)
+
+// Field selects the text of one element
+type Field struct {
+	Selector string `json:"selector"`
+	// Index picks the n-th match, defaults to the first one
+	Index int `json:"index,omitempty"`
+	// TrimPrefix is removed from the text, e.g. "by " for authors
+	TrimPrefix string `json:"trim_prefix,omitempty"`
+}
+
+// SourceConfig describes a review board scraped with CSS selectors
+type SourceConfig struct {
+	Name    string `json:"name"`
+	BaseURL string `json:"base_url"`
+	ListURL string `json:"list_url,omitempty"`
+	// PageURL is the URL of further list pages, "{page}" is replaced by
+	// the page number (starting at 2)
+	PageURL     string `json:"page_url,omitempty"`
+	StorageFile string `json:"storage_file,omitempty"`
+	// EmbedColor is a hex color such as "FFDFBA"
+	EmbedColor string `json:"embed_color,omitempty"`
+	// LinkSelector selects the story links on the list page
+	LinkSelector  string `json:"link_selector"`
+	LinkAttribute string `json:"link_attribute,omitempty"`
+	// IDPattern is a regular expression matched against story links, its
+	// first group is the story ID. Links that do not match are ignored.
+	IDPattern string `json:"id_pattern"`
+	Title     Field  `json:"title"`
+	Author    Field  `json:"author"`
+	Company   Field  `json:"company"`
+	Tag       Field  `json:"tag"`
+	// DescriptionSelector selects the paragraphs, headings and list items
+	// of the story body
+	DescriptionSelector string `json:"description_selector"`
+}
+
+// Config is the content of the selector sources file
+type Config struct {
+	Sources []SourceConfig `json:"sources"`
+}
+
+func init() {
+	registry.AddLoader(func() error {
+		path := os.Getenv("SELECTOR_SOURCES_FILE")
+		if path == "" {
+			return nil
+		}
+		return Register(path)
+	})
+}
+
+// Register registers every source of the config file. A source with the
+// name of a built-in source replaces it. The file is re-read whenever it
+// changes, so selectors can be fixed without a restart.
+func Register(path string) error {
+	cfg, modTime, err := load(path)
+	if err != nil {
+		return err
+	}
+
+	for _, sc := range cfg.Sources {
+		color, err := strconv.ParseInt(strings.TrimPrefix(sc.EmbedColor, "#"), 16, 32)
+		if err != nil && sc.EmbedColor != "" {
+			return errorhandling.NewError(errorhandling.ConfigError, "Invalid embed color for source "+sc.Name, err)
+		}
+
+		src := &source{
+			path:    path,
+			name:    sc.Name,
+			modTime: modTime,
+		}
+		if err := src.apply(sc); err != nil {
+			return err
+		}
+
+		storageFile := sc.StorageFile
+		if storageFile == "" {
+			storageFile = strings.ToLower(sc.Name) + "_sent_stories.json"
+		}
+
+		if _, exists := registry.Lookup(sc.Name); exists {
+			log.Printf("Selector source %s replaces the source registered under that name\n", sc.Name)
+		}
+		registry.Register(registry.Definition{
+			Name:        sc.Name,
+			BaseURL:     strings.TrimSuffix(sc.BaseURL, "/"),
+			StorageFile: storageFile,
+			EmbedColor:  int(color),
+			FetchLinks:  src.fetchStoryLinks,
+			ParseStory:  src.fetchAndParseStory,
+			StoryID:     src.storyID,
+			PageURL:     src.pageURL,
+		})
+	}
+
+	return nil
+}
+
+func load(path string) (*Config, time.Time, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, time.Time{}, errorhandling.NewError(errorhandling.ConfigError, "Failed to read selector sources file", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, time.Time{}, errorhandling.NewError(errorhandling.ConfigError, "Failed to read selector sources file", err)
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, time.Time{}, errorhandling.NewError(errorhandling.ConfigError, "Failed to parse selector sources file", err)
+	}
+
+	for _, sc := range cfg.Sources {
+		if sc.Name == "" || sc.BaseURL == "" || sc.LinkSelector == "" || sc.IDPattern == "" ||
+			sc.Company.Selector == "" || sc.DescriptionSelector == "" {
+			return nil, time.Time{}, errorhandling.NewError(errorhandling.ConfigError,
+				fmt.Sprintf("Selector source %q is missing name, base_url, link_selector, id_pattern, company or description_selector", sc.Name), nil)
+		}
+	}
+
+	return &cfg, info.ModTime(), nil
+}
+
+// source scrapes a site with the selectors of its config entry
+type source struct {
+	path    string
+	name    string
+	modTime time.Time
+	config  SourceConfig
+	idRegex *regexp.Regexp
+	mu      sync.RWMutex
+}
+
+func (s *source) apply(sc SourceConfig) error {
+	idRegex, err := regexp.Compile(sc.IDPattern)
+	if err != nil {
+		return errorhandling.NewError(errorhandling.ConfigError, "Invalid id_pattern for source "+sc.Name, err)
+	}
+	if idRegex.NumSubexp() < 1 {
+		return errorhandling.NewError(errorhandling.ConfigError, "id_pattern for source "+sc.Name+" needs a capture group", nil)
+	}
+
+	s.config = sc
+	s.idRegex = idRegex
+	return nil
+}
+
+// current returns the selectors, reloading them when the file changed. A
+// broken file keeps the last working selectors.
+func (s *source) current() (SourceConfig, *regexp.Regexp) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if info, err := os.Stat(s.path); err == nil && info.ModTime().After(s.modTime) {
+		s.modTime = info.ModTime()
+		if err := s.reload(); err != nil {
+			errorhandling.HandleError(err)
+		}
+	}
+
+	return s.config, s.idRegex
+}
+
+func (s *source) reload() error {
+	cfg, _, err := load(s.path)
+	if err != nil {
+		return err
+	}
+	for _, sc := range cfg.Sources {
+		if sc.Name == s.name {
+			return s.apply(sc)
+		}
+	}
+	return errorhandling.NewError(errorhandling.ConfigError, "Selector source "+s.name+" was removed from "+s.path, nil)
+}
+
+func (s *source) storyID(link string) string {
+	_, idRegex := s.current()
+	if match := idRegex.FindStringSubmatch(link); len(match) > 1 {
+		return match[1]
+	}
+	return link
+}
+
+// pageURL returns the n-th list page
+func (s *source) pageURL(baseURL string, page int) string {
+	sc, _ := s.current()
+
+	if page > 1 && sc.PageURL != "" {
+		return strings.ReplaceAll(sc.PageURL, "{page}", strconv.Itoa(page))
+	}
+	if sc.ListURL != "" {
+		return sc.ListURL
+	}
+	return baseURL
+}
+
+func (s *source) fetchStoryLinks(b *base.BaseService, listURL string) ([]string, error) {
+	sc, idRegex := s.current()
+
+	resp, err := b.Fetch(listURL)
+	if err != nil {
+		return nil, errorhandling.NewError(errorhandling.NetworkError, "Failed to fetch story links", err)
+	}
+	defer resp.Body.Close()
+
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		return nil, errorhandling.NewError(errorhandling.ParseError, "Failed to parse HTML", err)
+	}
+
+	listBase, err := url.Parse(listURL)
+	if err != nil {
+		return nil, err
+	}
+
+	attribute := sc.LinkAttribute
+	if attribute == "" {
+		attribute = "href"
+	}
+
+	var links []string
+	seen := make(map[string]bool)
+	doc.Find(sc.LinkSelector).Each(func(i int, sel *goquery.Selection) {
+		href, exists := sel.Attr(attribute)
+		if !exists {
+			return
+		}
+		ref, err := url.Parse(strings.TrimSpace(href))
+		if err != nil {
+			return
+		}
+		link := listBase.ResolveReference(ref).String()
+		if !idRegex.MatchString(link) || seen[link] {
+			return
+		}
+		seen[link] = true
+		links = append(links, link)
+	})
+
+	return links, nil
+}
+
+func (s *source) fetchAndParseStory(b *base.BaseService, link string) (*base.Story, error) {
+	sc, _ := s.current()
+
+	resp, err := b.Fetch(link)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	story := &base.Story{
+		Link:    link,
+		Title:   fieldText(doc, sc.Title),
+		Author:  fieldText(doc, sc.Author),
+		Company: fieldText(doc, sc.Company),
+		Tag:     fieldText(doc, sc.Tag),
+	}
+
+	if len(story.Company) == 0 {
+		return nil, errors.New("empty company name")
+	}
+
+	var description strings.Builder
+	doc.Find(sc.DescriptionSelector).Each(func(i int, sel *goquery.Selection) {
+		text := strings.TrimSpace(sel.Text())
+		if text == "" {
+			return
+		}
+		switch {
+		case sel.Is("h1, h2, h3"):
+			description.WriteString("\n### " + text + " ###\n")
+		case sel.Is("h4, h5, h6"):
+			description.WriteString("\n## " + text + " ##\n")
+		case sel.Is("li"):
+			description.WriteString("- " + text + "\n")
+		default:
+			description.WriteString(text + "\n")
+		}
+	})
+	story.Description = strings.TrimSpace(description.String())
+
+	if len(story.Description) == 0 {
+		return nil, errors.New("empty description")
+	}
+
+	return story, nil
+}
+
+func fieldText(doc *goquery.Document, field Field) string {
+	if field.Selector == "" {
+		return ""
+	}
+	text := strings.TrimSpace(doc.Find(field.Selector).Eq(field.Index).Text())
+	return strings.TrimSpace(strings.TrimPrefix(text, field.TrimPrefix))
+}
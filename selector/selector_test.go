@@ -0,0 +1,180 @@
+package selector
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/base"
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/registry"
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/storage"
+)
+
+const validSource = `{
+	"name": "board",
+	"base_url": "https://board.example.com/",
+	"list_url": "https://board.example.com/latest",
+	"page_url": "https://board.example.com/latest?p={page}",
+	"link_selector": "a.story",
+	"id_pattern": "/reviews/(\\d+)$",
+	"title": {"selector": "h1"},
+	"author": {"selector": ".author", "trim_prefix": "by "},
+	"company": {"selector": ".badge", "index": 0},
+	"tag": {"selector": ".badge", "index": 1},
+	"description_selector": "article p, article li"
+}`
+
+// writeConfig writes a sources file and dates it at modTime
+func writeConfig(t *testing.T, path, sources string, modTime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(`{"sources": [`+sources+`]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRegisterValidatesSources(t *testing.T) {
+	tests := []struct {
+		name    string
+		sources string
+		wantErr string
+	}{
+		{"valid", validSource, ""},
+		{"missing id_pattern", strings.Replace(validSource, `"id_pattern": "/reviews/(\\d+)$",`, "", 1), "missing"},
+		{"missing company", strings.Replace(validSource, `"company": {"selector": ".badge", "index": 0},`, "", 1), "missing"},
+		{"invalid id_pattern", strings.Replace(validSource, `/reviews/(\\d+)$`, `/reviews/(\\d+$`, 1), "Invalid id_pattern"},
+		{"id_pattern without group", strings.Replace(validSource, `/reviews/(\\d+)$`, `/reviews/\\d+$`, 1), "capture group"},
+		{"invalid color", strings.Replace(validSource, `"name": "board",`, `"name": "board", "embed_color": "orange",`, 1), "Invalid embed color"},
+		{"invalid JSON", `{"name": `, "Failed to parse"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "sources.json")
+			writeConfig(t, path, tt.sources, time.Now())
+
+			err := Register(path)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Register: %v", err)
+				}
+				if _, exists := registry.Lookup("board"); !exists {
+					t.Error("source was not registered")
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Register = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	if err := Register(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("Register accepted a missing file")
+	}
+}
+
+// newSource loads the first source of a config file like Register does
+func newSource(t *testing.T, path string) *source {
+	t.Helper()
+	cfg, modTime, err := load(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	src := &source{path: path, name: cfg.Sources[0].Name, modTime: modTime}
+	if err := src.apply(cfg.Sources[0]); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	return src
+}
+
+func TestStoryIDAndPageURL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sources.json")
+	writeConfig(t, path, validSource, time.Now())
+	src := newSource(t, path)
+
+	if id := src.storyID("https://board.example.com/reviews/123"); id != "123" {
+		t.Errorf("storyID = %q, want the capture group", id)
+	}
+	if id := src.storyID("https://board.example.com/about"); id != "https://board.example.com/about" {
+		t.Errorf("storyID of an unmatched link = %q, want the link", id)
+	}
+
+	pages := map[int]string{
+		1: "https://board.example.com/latest",
+		2: "https://board.example.com/latest?p=2",
+		3: "https://board.example.com/latest?p=3",
+	}
+	for page, want := range pages {
+		if got := src.pageURL("https://board.example.com", page); got != want {
+			t.Errorf("pageURL(%d) = %q, want %q", page, got, want)
+		}
+	}
+
+	// Without page_url and list_url every page is the base URL
+	plain := strings.Replace(validSource, `"list_url": "https://board.example.com/latest",`, "", 1)
+	plain = strings.Replace(plain, `"page_url": "https://board.example.com/latest?p={page}",`, "", 1)
+	writeConfig(t, path, plain, time.Now().Add(time.Minute))
+	if got := src.pageURL("https://board.example.com", 2); got != "https://board.example.com" {
+		t.Errorf("pageURL without page_url = %q, want the base URL", got)
+	}
+}
+
+func TestSelectorsAreReloaded(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sources.json")
+	start := time.Now().Add(-time.Hour)
+	writeConfig(t, path, validSource, start)
+	src := newSource(t, path)
+
+	fixed := strings.Replace(validSource, `/reviews/(\\d+)$`, `/r/(\\d+)$`, 1)
+	writeConfig(t, path, fixed, start.Add(time.Minute))
+	if id := src.storyID("https://board.example.com/r/7"); id != "7" {
+		t.Errorf("storyID after the edit = %q, want the new pattern to apply", id)
+	}
+
+	// A broken file keeps the last working selectors
+	writeConfig(t, path, `{"name": `, start.Add(2*time.Minute))
+	if id := src.storyID("https://board.example.com/r/8"); id != "8" {
+		t.Errorf("storyID after a broken edit = %q, want the last working pattern", id)
+	}
+
+	// An unchanged file is not read again
+	writeConfig(t, path, validSource, start.Add(2*time.Minute))
+	if id := src.storyID("https://board.example.com/r/9"); id != "9" {
+		t.Errorf("storyID = %q, want the file to be read only when its date changes", id)
+	}
+}
+
+func TestFetchStoryLinksFiltersByIDPattern(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<ul>
+			<li><a class="story" href="/reviews/2">Two</a></li>
+			<li><a class="story" href="reviews/1">One</a></li>
+			<li><a class="story" href="/reviews/2">Two again</a></li>
+			<li><a class="story" href="/reviews/new">Write a review</a></li>
+			<li><a class="other" href="/reviews/3">Not a story link</a></li>
+		</ul>`))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "sources.json")
+	writeConfig(t, path, validSource, time.Now())
+	src := newSource(t, path)
+
+	b := base.NewBaseService(storage.NewMemoryStore(), server.URL, nil)
+	links, err := src.fetchStoryLinks(b, server.URL+"/latest/")
+	if err != nil {
+		t.Fatalf("fetchStoryLinks: %v", err)
+	}
+
+	want := []string{server.URL + "/reviews/2", server.URL + "/latest/reviews/1"}
+	if strings.Join(links, " ") != strings.Join(want, " ") {
+		t.Errorf("links = %v, want %v", links, want)
+	}
+}
@@ -0,0 +1,10 @@
+// Package sources registers every built-in story source. Import it for its
+// side effects; new sites only need to be added here.
+package sources
+
+import (
+	_ "github.com/nahidhasan98/deshimula-notifier-unofficial/mula"
+	_ "github.com/nahidhasan98/deshimula-notifier-unofficial/oak"
+	_ "github.com/nahidhasan98/deshimula-notifier-unofficial/rss"
+	_ "github.com/nahidhasan98/deshimula-notifier-unofficial/selector"
+)
@@ -0,0 +1,203 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/errorhandling"
+	bolt "go.etcd.io/bbolt"
+)
+
+// BoltStore keeps the records of one source as JSON values in a bucket of
+// a BoltDB file that can be shared by every source
+type BoltStore struct {
+	path   string
+	db     *bolt.DB
+	bucket []byte
+}
+
+type boltDB struct {
+	db   *bolt.DB
+	refs int
+}
+
+var (
+	boltDBs   = make(map[string]*boltDB)
+	boltDBsMu sync.Mutex
+)
+
+// OpenBolt opens the stories of a source in the BoltDB file at path,
+// creating the file if needed
+func OpenBolt(path string, source string) (*BoltStore, error) {
+	boltDBsMu.Lock()
+	defer boltDBsMu.Unlock()
+
+	shared, exists := boltDBs[path]
+	if !exists {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, err
+		}
+
+		// A second process holding the file lock makes Open fail instead
+		// of blocking forever
+		db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: 5 * time.Second})
+		if err != nil {
+			return nil, err
+		}
+		shared = &boltDB{db: db}
+	}
+
+	bucket := []byte(source)
+	err := shared.db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucket)
+		return err
+	})
+	if err != nil {
+		// Only a file no other source uses is closed
+		if !exists {
+			shared.db.Close()
+		}
+		return nil, err
+	}
+	// Shared once the first source opened successfully
+	boltDBs[path] = shared
+	shared.refs++
+
+	return &BoltStore{path: path, db: shared.db, bucket: bucket}, nil
+}
+
+func (s *BoltStore) Has(id string) (bool, error) {
+	var exists bool
+	err := s.db.View(func(tx *bolt.Tx) error {
+		exists = tx.Bucket(s.bucket).Get([]byte(id)) != nil
+		return nil
+	})
+	return exists, err
+}
+
+func (s *BoltStore) Get(id string) (Record, bool, error) {
+	var record Record
+	var exists bool
+	err := s.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(s.bucket).Get([]byte(id))
+		if value == nil {
+			return nil
+		}
+		exists = true
+		return json.Unmarshal(value, &record)
+	})
+	if err != nil {
+		return Record{}, false, err
+	}
+	return record, exists, nil
+}
+
+func (s *BoltStore) Add(id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(s.bucket)
+		if bucket.Get([]byte(id)) != nil {
+			return nil
+		}
+		return putRecord(bucket, id, Record{FirstSeen: time.Now().UTC()})
+	})
+}
+
+func (s *BoltStore) Save(id string, record Record) error {
+	if record.FirstSeen.IsZero() {
+		record.FirstSeen = time.Now().UTC()
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return putRecord(tx.Bucket(s.bucket), id, record)
+	})
+}
+
+func (s *BoltStore) List() (map[string]Record, error) {
+	stories := make(map[string]Record)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(s.bucket).ForEach(func(key, value []byte) error {
+			var record Record
+			if err := json.Unmarshal(value, &record); err != nil {
+				return err
+			}
+			stories[string(key)] = record
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stories, nil
+}
+
+func (s *BoltStore) Delete(id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(s.bucket).Delete([]byte(id))
+	})
+}
+
+// Close closes the file once every source using it is closed
+func (s *BoltStore) Close() error {
+	boltDBsMu.Lock()
+	defer boltDBsMu.Unlock()
+
+	shared, exists := boltDBs[s.path]
+	if !exists {
+		return nil
+	}
+	if shared.refs--; shared.refs > 0 {
+		return nil
+	}
+	delete(boltDBs, s.path)
+	return shared.db.Close()
+}
+
+// readBolt reads the records of a source from the BoltDB file at path,
+// opened read-only. The running service holds an exclusive lock on the file,
+// so this fails while it runs.
+func readBolt(path string, source string) (map[string]Record, error) {
+	stories := make(map[string]Record)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return stories, nil
+	}
+
+	db, err := bolt.Open(path, 0644, &bolt.Options{ReadOnly: true, Timeout: time.Second})
+	if errors.Is(err, bolt.ErrTimeout) {
+		return nil, errorhandling.NewError(errorhandling.StorageError,
+			path+" is locked by the running service, stop the service first", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	err = db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(source))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(key, value []byte) error {
+			var record Record
+			if err := json.Unmarshal(value, &record); err != nil {
+				return err
+			}
+			stories[string(key)] = record
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stories, nil
+}
+
+func putRecord(bucket *bolt.Bucket, id string, record Record) error {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return bucket.Put([]byte(id), value)
+}
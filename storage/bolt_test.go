@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadBoltWhileOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), boltFile)
+	store, err := OpenBolt(path, "mula")
+	if err != nil {
+		t.Fatalf("OpenBolt: %v", err)
+	}
+	defer store.Close()
+
+	// The running service keeps the file locked
+	if _, err := readBolt(path, "mula"); err == nil || !strings.Contains(err.Error(), "stop the service") {
+		t.Errorf("readBolt = %v, want an error asking to stop the service", err)
+	}
+}
+
+func TestOpenBoltReleasesTheFileOnError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), boltFile)
+
+	// A bucket needs a name
+	if _, err := OpenBolt(path, ""); err == nil {
+		t.Fatal("OpenBolt accepted an empty source")
+	}
+
+	boltDBsMu.Lock()
+	_, shared := boltDBs[path]
+	boltDBsMu.Unlock()
+	if shared {
+		t.Error("the failed open is still shared")
+	}
+	if _, err := readBolt(path, "mula"); err != nil {
+		t.Errorf("readBolt after the failed open: %v", err)
+	}
+}
@@ -0,0 +1,442 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/errorhandling"
+)
+
+const (
+	// jsonBackups is the number of rotated backups kept next to the file
+	jsonBackups = 3
+	// jsonBackupInterval is the minimum age of the newest backup before
+	// the next snapshot rotates them
+	jsonBackupInterval = time.Hour
+	// jsonCompactEntries is the number of log entries that triggers a
+	// snapshot
+	jsonCompactEntries = 1000
+)
+
+// JSONStore keeps the records of one source in a JSON snapshot file plus an
+// append-only log (<file>.wal) of the changes since, so a change costs the
+// same however many stories are stored. The log is compacted into the
+// snapshot every jsonCompactEntries changes and on Close.
+//
+// Snapshots go to a temporary file that is renamed over the old one, so a
+// crash leaves either the old or the new file. The snapshot is copied to
+// <file>.bak.1 .. <file>.bak.N at most once per jsonBackupInterval.
+type JSONStore struct {
+	filepath   string
+	stories    sync.Map
+	mu         sync.Mutex
+	lastBackup time.Time
+	wal        *os.File
+	walEntries int
+}
+
+// walEntry is one line of the log
+type walEntry struct {
+	Op     string  `json:"op"`
+	ID     string  `json:"id"`
+	Record *Record `json:"record,omitempty"`
+}
+
+// Log operations
+const (
+	walSave   = "save"
+	walDelete = "delete"
+)
+
+// NewJSONStore loads the snapshot at storagePath, if it exists, and replays
+// the log. A corrupted snapshot is moved aside and the newest readable
+// backup is restored; without one the store starts empty. Both are reported
+// as errors.
+func NewJSONStore(storagePath string) (*JSONStore, error) {
+	if err := os.MkdirAll(filepath.Dir(storagePath), 0755); err != nil {
+		return nil, err
+	}
+
+	s := &JSONStore{
+		filepath: storagePath,
+	}
+	if info, err := os.Stat(backupPath(storagePath, 1)); err == nil {
+		s.lastBackup = info.ModTime()
+	}
+
+	if err := s.loadSnapshot(); err != nil {
+		return nil, err
+	}
+
+	logged, err := s.replayWAL()
+	if err != nil {
+		return nil, err
+	}
+
+	s.wal, err = os.OpenFile(s.walPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	// Compacting empties the log, so a torn last line is not appended to
+	if logged {
+		if err := s.compact(); err != nil {
+			s.wal.Close()
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// loadSnapshot loads the snapshot file, recovering from a backup if it is
+// corrupted
+func (s *JSONStore) loadSnapshot() error {
+	storagePath := s.filepath
+	if _, err := os.Stat(storagePath); err != nil {
+		return nil
+	}
+
+	stories, err := loadJSON(storagePath)
+	if err == nil {
+		s.load(stories)
+		return nil
+	}
+	if !isCorrupt(err) {
+		return err
+	}
+
+	corruptPath := storagePath + ".corrupt-" + time.Now().UTC().Format("20060102T150405")
+	if renameErr := os.Rename(storagePath, corruptPath); renameErr != nil {
+		return renameErr
+	}
+
+	for i := 1; i <= jsonBackups; i++ {
+		backup := backupPath(storagePath, i)
+		stories, backupErr := loadJSON(backup)
+		if backupErr != nil {
+			continue
+		}
+
+		s.load(stories)
+		if err := s.save(); err != nil {
+			return err
+		}
+		errorhandling.HandleError(errorhandling.NewError(errorhandling.StorageError,
+			"Corrupted story storage "+storagePath+" moved to "+corruptPath+", restored "+backup, err))
+		return nil
+	}
+
+	errorhandling.HandleError(errorhandling.NewError(errorhandling.StorageError,
+		"Corrupted story storage "+storagePath+" moved to "+corruptPath+", no readable backup, starting empty", err))
+	return nil
+}
+
+// readJSONStore reads the snapshot and log at storagePath without writing
+// anything, a corrupted snapshot is an error
+func readJSONStore(storagePath string) (map[string]Record, error) {
+	s := &JSONStore{filepath: storagePath}
+	if _, err := os.Stat(storagePath); err == nil {
+		stories, err := loadJSON(storagePath)
+		if err != nil {
+			return nil, err
+		}
+		s.load(stories)
+	}
+
+	if _, err := s.replayWAL(); err != nil {
+		return nil, err
+	}
+	return s.List()
+}
+
+func (s *JSONStore) walPath() string {
+	return s.filepath + ".wal"
+}
+
+// replayWAL applies the logged changes to the loaded snapshot and reports
+// whether the log had any content. Replaying is idempotent, so a log that
+// was already compacted before a crash is harmless. A torn last line from a
+// crash during an append is skipped.
+func (s *JSONStore) replayWAL() (bool, error) {
+	data, err := os.ReadFile(s.walPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	lines := bytes.Split(data, []byte("\n"))
+	for i, line := range lines {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		var entry walEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			if i < len(lines)-1 {
+				errorhandling.HandleError(errorhandling.NewError(errorhandling.StorageError,
+					"Skipping corrupted entry "+strconv.Itoa(i+1)+" of "+s.walPath(), err))
+			}
+			continue
+		}
+
+		switch entry.Op {
+		case walSave:
+			if entry.Record != nil {
+				s.stories.Store(entry.ID, *entry.Record)
+			}
+		case walDelete:
+			s.stories.Delete(entry.ID)
+		}
+	}
+	return len(data) > 0, nil
+}
+
+// appendWAL logs a change and compacts the log once it is long enough
+func (s *JSONStore) appendWAL(entry walEntry) error {
+	if s.wal == nil {
+		return errors.New("store is closed")
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	if _, err := s.wal.Write(line); err != nil {
+		return err
+	}
+	if err := s.wal.Sync(); err != nil {
+		return err
+	}
+
+	s.walEntries++
+	if s.walEntries >= jsonCompactEntries {
+		return s.compact()
+	}
+	return nil
+}
+
+// compact writes a snapshot and empties the log
+func (s *JSONStore) compact() error {
+	if err := s.save(); err != nil {
+		return err
+	}
+	if err := s.wal.Truncate(0); err != nil {
+		return err
+	}
+	s.walEntries = 0
+	return s.wal.Sync()
+}
+
+// corruptError marks a file that was read but could not be decoded
+type corruptError struct {
+	err error
+}
+
+func (e *corruptError) Error() string {
+	return "corrupted JSON: " + e.err.Error()
+}
+
+func (e *corruptError) Unwrap() error {
+	return e.err
+}
+
+func isCorrupt(err error) bool {
+	var corrupt *corruptError
+	return errors.As(err, &corrupt)
+}
+
+// loadJSON reads a storage file
+func loadJSON(path string) (map[string]Record, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, &corruptError{err}
+	}
+
+	stories := make(map[string]Record, len(raw))
+	for link, value := range raw {
+		var record Record
+		// Older files only stored true for every seen story
+		if !bytes.Equal(value, []byte("true")) {
+			if err := json.Unmarshal(value, &record); err != nil {
+				return nil, &corruptError{err}
+			}
+		}
+		stories[link] = record
+	}
+	return stories, nil
+}
+
+func (s *JSONStore) load(stories map[string]Record) {
+	for link, record := range stories {
+		s.stories.Store(link, record)
+	}
+}
+
+func (s *JSONStore) Has(link string) (bool, error) {
+	_, exists := s.stories.Load(link)
+	return exists, nil
+}
+
+func (s *JSONStore) Get(link string) (Record, bool, error) {
+	value, exists := s.stories.Load(link)
+	if !exists {
+		return Record{}, false, nil
+	}
+	return value.(Record), true, nil
+}
+
+func (s *JSONStore) Add(link string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record := Record{FirstSeen: time.Now().UTC()}
+	if _, exists := s.stories.LoadOrStore(link, record); exists {
+		return nil
+	}
+	return s.appendWAL(walEntry{Op: walSave, ID: link, Record: &record})
+}
+
+func (s *JSONStore) Save(link string, record Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if record.FirstSeen.IsZero() {
+		record.FirstSeen = time.Now().UTC()
+	}
+	s.stories.Store(link, record)
+	return s.appendWAL(walEntry{Op: walSave, ID: link, Record: &record})
+}
+
+func (s *JSONStore) List() (map[string]Record, error) {
+	stories := make(map[string]Record)
+	s.stories.Range(func(key, value interface{}) bool {
+		stories[key.(string)] = value.(Record)
+		return true
+	})
+	return stories, nil
+}
+
+func (s *JSONStore) Delete(link string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stories.Delete(link)
+	return s.appendWAL(walEntry{Op: walDelete, ID: link})
+}
+
+// Close compacts the log into the snapshot
+func (s *JSONStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.wal == nil {
+		return nil
+	}
+	err := s.compact()
+	if closeErr := s.wal.Close(); err == nil {
+		err = closeErr
+	}
+	s.wal = nil
+	return err
+}
+
+// save writes the snapshot
+func (s *JSONStore) save() error {
+	stories, _ := s.List()
+
+	data, err := json.Marshal(stories)
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(s.filepath, data, 0644); err != nil {
+		return err
+	}
+
+	if time.Since(s.lastBackup) >= jsonBackupInterval {
+		if err := s.backup(); err != nil {
+			errorhandling.HandleError(errorhandling.NewError(errorhandling.StorageError, "Failed to back up story storage", err))
+		}
+	}
+	return nil
+}
+
+// backup shifts <file>.bak.1 .. <file>.bak.N-1 up by one and copies the
+// freshly written file to <file>.bak.1
+func (s *JSONStore) backup() error {
+	for i := jsonBackups - 1; i >= 1; i-- {
+		src := backupPath(s.filepath, i)
+		if _, err := os.Stat(src); err == nil {
+			if err := os.Rename(src, backupPath(s.filepath, i+1)); err != nil {
+				return err
+			}
+		}
+	}
+
+	data, err := os.ReadFile(s.filepath)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(backupPath(s.filepath, 1), data, 0644); err != nil {
+		return err
+	}
+	s.lastBackup = time.Now()
+	return nil
+}
+
+func backupPath(path string, n int) string {
+	return path + ".bak." + strconv.Itoa(n)
+}
+
+// writeFileAtomic writes data to a temporary file in the same directory,
+// syncs it and renames it over path
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	// Removing fails harmlessly once the rename happened
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	// Persist the rename itself
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
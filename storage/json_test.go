@@ -0,0 +1,161 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJSONStoreDropsTornWALLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stories.json")
+
+	store, err := NewJSONStore(path)
+	if err != nil {
+		t.Fatalf("NewJSONStore: %v", err)
+	}
+	if err := store.Save("1", Record{Title: "First"}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	// A crash while appending the first entry after a compaction leaves
+	// only a torn line
+	wal, err := os.OpenFile(path+".wal", os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wal.WriteString(`{"op":"save","id":"2","rec`)
+	wal.Close()
+
+	store, err = NewJSONStore(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if err := store.Save("3", Record{Title: "Third"}); err != nil {
+		t.Fatalf("Save after reopen: %v", err)
+	}
+	// Crash again, the entry of story 3 must not be glued to the torn line
+	store.wal.Close()
+
+	store, err = NewJSONStore(path)
+	if err != nil {
+		t.Fatalf("second reopen: %v", err)
+	}
+	defer store.Close()
+
+	for id, title := range map[string]string{"1": "First", "3": "Third"} {
+		if record, exists := mustGet(t, store, id); !exists || record.Title != title {
+			t.Errorf("story %s = %+v, %v, want title %q", id, record, exists, title)
+		}
+	}
+	if mustHave(t, store, "2") {
+		t.Error("torn entry was replayed")
+	}
+}
+
+func TestJSONStoreRestoresCorruptedSnapshot(t *testing.T) {
+	tests := []struct {
+		name    string
+		backups []string
+		want    string
+	}{
+		{"newest readable backup", []string{`{"1": {"title": "Tor`, `{"2": {"title": "Second"}}`, `{"3": {"title": "Third"}}`}, "2"},
+		{"no readable backup", []string{`{`}, ""},
+		{"no backup", nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "stories.json")
+			corrupted := `{"0": {"title": "Zer`
+			if err := os.WriteFile(path, []byte(corrupted), 0644); err != nil {
+				t.Fatal(err)
+			}
+			for i, backup := range tt.backups {
+				if err := os.WriteFile(backupPath(path, i+1), []byte(backup), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			store, err := NewJSONStore(path)
+			if err != nil {
+				t.Fatalf("NewJSONStore: %v", err)
+			}
+			defer store.Close()
+
+			stories, _ := store.List()
+			if tt.want == "" && len(stories) != 0 {
+				t.Errorf("stories = %+v, want an empty store", stories)
+			}
+			if _, exists := stories[tt.want]; tt.want != "" && (!exists || len(stories) != 1) {
+				t.Errorf("stories = %+v, want only story %s", stories, tt.want)
+			}
+
+			moved, _ := filepath.Glob(path + ".corrupt-*")
+			if len(moved) != 1 {
+				t.Fatalf("corrupted files = %v, want the snapshot moved aside", moved)
+			}
+			if data, _ := os.ReadFile(moved[0]); string(data) != corrupted {
+				t.Errorf("moved file = %q, want the corrupted snapshot", data)
+			}
+			if tt.want != "" {
+				if _, err := loadJSON(path); err != nil {
+					t.Errorf("restored snapshot: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestJSONStoreRotatesBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stories.json")
+
+	// save writes a snapshot with title, backing it up if rotate is set
+	save := func(title string, rotate bool) {
+		t.Helper()
+		store, err := NewJSONStore(path)
+		if err != nil {
+			t.Fatalf("NewJSONStore: %v", err)
+		}
+		if err := store.Save("1", Record{Title: title}); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+		if rotate {
+			store.lastBackup = time.Time{}
+		}
+		if err := store.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+	}
+	backups := func() []string {
+		t.Helper()
+		var titles []string
+		for i := 1; i <= jsonBackups+1; i++ {
+			stories, err := loadJSON(backupPath(path, i))
+			if os.IsNotExist(err) {
+				break
+			}
+			if err != nil {
+				t.Fatalf("backup %d: %v", i, err)
+			}
+			titles = append(titles, stories["1"].Title)
+		}
+		return titles
+	}
+
+	for _, title := range []string{"v1", "v2", "v3", "v4"} {
+		save(title, true)
+	}
+	if got := strings.Join(backups(), " "); got != "v4 v3 v2" {
+		t.Errorf("backups = %q, want the %d newest snapshots, newest first", got, jsonBackups)
+	}
+
+	// The newest backup is less than jsonBackupInterval old
+	save("v5", false)
+	if got := strings.Join(backups(), " "); got != "v4 v3 v2" {
+		t.Errorf("backups = %q, want no rotation within the backup interval", got)
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package storage
+
+// Lock is a no-op on systems without flock, the service has to be stopped
+// by hand before running the maintenance commands
+func Lock(dir string) (*DirLock, error) {
+	return &DirLock{}, nil
+}
+
+// Unlock releases the lock
+func (l *DirLock) Unlock() error {
+	return nil
+}
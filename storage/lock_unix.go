@@ -0,0 +1,38 @@
+//go:build unix
+
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// Lock takes the lock of the storage in dir, so the service and the
+// maintenance commands never write the same stores at once. It fails with
+// ErrLocked while another process holds it; the lock is released on Unlock
+// or when the process exits.
+func Lock(dir string) (*DirLock, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(filepath.Join(dir, lockFile), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		file.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, ErrLocked
+		}
+		return nil, err
+	}
+	return &DirLock{file: file}, nil
+}
+
+// Unlock releases the lock
+func (l *DirLock) Unlock() error {
+	return l.file.Close()
+}
@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps the records in memory only, for tests and dry runs
+type MemoryStore struct {
+	stories map[string]Record
+	mu      sync.RWMutex
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{stories: make(map[string]Record)}
+}
+
+func (s *MemoryStore) Has(id string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, exists := s.stories[id]
+	return exists, nil
+}
+
+func (s *MemoryStore) Get(id string) (Record, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	record, exists := s.stories[id]
+	return record, exists, nil
+}
+
+func (s *MemoryStore) Add(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.stories[id]; !exists {
+		s.stories[id] = Record{FirstSeen: time.Now().UTC()}
+	}
+	return nil
+}
+
+func (s *MemoryStore) Save(id string, record Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if record.FirstSeen.IsZero() {
+		record.FirstSeen = time.Now().UTC()
+	}
+	s.stories[id] = record
+	return nil
+}
+
+func (s *MemoryStore) List() (map[string]Record, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stories := make(map[string]Record, len(s.stories))
+	for id, record := range s.stories {
+		stories[id] = record
+	}
+	return stories, nil
+}
+
+func (s *MemoryStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.stories, id)
+	return nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,268 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS stories (
+	source       TEXT NOT NULL,
+	id           TEXT NOT NULL,
+	link         TEXT NOT NULL DEFAULT '',
+	title        TEXT NOT NULL DEFAULT '',
+	company      TEXT NOT NULL DEFAULT '',
+	tag          TEXT NOT NULL DEFAULT '',
+	author       TEXT NOT NULL DEFAULT '',
+	description  TEXT NOT NULL DEFAULT '',
+	hash         TEXT NOT NULL DEFAULT '',
+	first_seen   INTEGER NOT NULL,
+	published_at INTEGER,
+	scraped_at   INTEGER,
+	delivered_at INTEGER,
+	trending_at  INTEGER,
+	removed_at   INTEGER,
+	comments     TEXT,
+	votes        TEXT,
+	removal_checked_at INTEGER,
+	removal_errors     INTEGER NOT NULL DEFAULT 0,
+	removal_misses     INTEGER NOT NULL DEFAULT 0,
+	messages           TEXT,
+	PRIMARY KEY (source, id)
+);
+CREATE INDEX IF NOT EXISTS stories_first_seen ON stories (source, first_seen);
+`
+
+const sqliteColumns = `id, link, title, company, tag, author, description, hash,
+	first_seen, published_at, scraped_at, delivered_at, trending_at, removed_at, comments, votes,
+	removal_checked_at, removal_errors, removal_misses, messages`
+
+// SQLiteStore keeps the records of one source in a SQLite database that
+// can be shared by every source
+type SQLiteStore struct {
+	path   string
+	db     *sql.DB
+	source string
+}
+
+type sqliteDB struct {
+	db   *sql.DB
+	refs int
+}
+
+var (
+	sqliteDBs   = make(map[string]*sqliteDB)
+	sqliteDBsMu sync.Mutex
+)
+
+// OpenSQLite opens the stories of a source in the database at path,
+// creating the database if needed
+func OpenSQLite(path string, source string) (*SQLiteStore, error) {
+	sqliteDBsMu.Lock()
+	defer sqliteDBsMu.Unlock()
+
+	shared, exists := sqliteDBs[path]
+	if !exists {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, err
+		}
+
+		db, err := sql.Open("sqlite", "file:"+path+"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)")
+		if err != nil {
+			return nil, err
+		}
+		// SQLite allows a single writer, queue writes in the pool instead
+		// of failing with SQLITE_BUSY
+		db.SetMaxOpenConns(1)
+
+		if _, err := db.Exec(sqliteSchema); err != nil {
+			db.Close()
+			return nil, err
+		}
+		shared = &sqliteDB{db: db}
+		sqliteDBs[path] = shared
+	}
+	shared.refs++
+
+	return &SQLiteStore{path: path, db: shared.db, source: source}, nil
+}
+
+func (s *SQLiteStore) Has(id string) (bool, error) {
+	var exists int
+	err := s.db.QueryRow(`SELECT 1 FROM stories WHERE source = ? AND id = ?`, s.source, id).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *SQLiteStore) Get(id string) (Record, bool, error) {
+	row := s.db.QueryRow(`SELECT `+sqliteColumns+` FROM stories WHERE source = ? AND id = ?`, s.source, id)
+	_, record, err := scanRecord(row)
+	if err == sql.ErrNoRows {
+		return Record{}, false, nil
+	}
+	if err != nil {
+		return Record{}, false, err
+	}
+	return record, true, nil
+}
+
+func (s *SQLiteStore) Add(id string) error {
+	_, err := s.db.Exec(`INSERT OR IGNORE INTO stories (source, id, first_seen) VALUES (?, ?, ?)`,
+		s.source, id, time.Now().UTC().UnixNano())
+	return err
+}
+
+func (s *SQLiteStore) Save(id string, record Record) error {
+	if record.FirstSeen.IsZero() {
+		record.FirstSeen = time.Now().UTC()
+	}
+
+	comments, err := json.Marshal(record.Comments)
+	if err != nil {
+		return err
+	}
+	votes, err := json.Marshal(record.Votes)
+	if err != nil {
+		return err
+	}
+	messages, err := json.Marshal(record.Messages)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(`INSERT OR REPLACE INTO stories (source, `+sqliteColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		s.source, id, record.Link, record.Title, record.Company, record.Tag, record.Author,
+		record.Description, record.Hash, record.FirstSeen.UnixNano(),
+		nullTime(&record.PublishedAt), nullTime(&record.ScrapedAt), nullTime(record.DeliveredAt), nullTime(record.TrendingAt),
+		nullTime(record.RemovedAt), string(comments), string(votes),
+		nullTime(record.RemovalCheckedAt), record.RemovalErrors, record.RemovalMisses, string(messages))
+	return err
+}
+
+func (s *SQLiteStore) List() (map[string]Record, error) {
+	rows, err := s.db.Query(`SELECT `+sqliteColumns+` FROM stories WHERE source = ?`, s.source)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stories := make(map[string]Record)
+	for rows.Next() {
+		id, record, err := scanRecord(rows)
+		if err != nil {
+			return nil, err
+		}
+		stories[id] = record
+	}
+	return stories, rows.Err()
+}
+
+func (s *SQLiteStore) Delete(id string) error {
+	_, err := s.db.Exec(`DELETE FROM stories WHERE source = ? AND id = ?`, s.source, id)
+	return err
+}
+
+// Close closes the database once every source using it is closed
+func (s *SQLiteStore) Close() error {
+	sqliteDBsMu.Lock()
+	defer sqliteDBsMu.Unlock()
+
+	shared, exists := sqliteDBs[s.path]
+	if !exists {
+		return nil
+	}
+	if shared.refs--; shared.refs > 0 {
+		return nil
+	}
+	delete(sqliteDBs, s.path)
+	return shared.db.Close()
+}
+
+// readSQLite reads the records of a source from the database at path,
+// opened read-only
+func readSQLite(path string, source string) (map[string]Record, error) {
+	stories := make(map[string]Record)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return stories, nil
+	}
+
+	db, err := sql.Open("sqlite", "file:"+path+"?mode=ro&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	store := &SQLiteStore{path: path, db: db, source: source}
+	return store.List()
+}
+
+// scanRecord reads a row selected with sqliteColumns
+func scanRecord(row interface{ Scan(dest ...any) error }) (string, Record, error) {
+	var (
+		id                            string
+		record                        Record
+		firstSeen                     int64
+		published, scraped, delivered sql.NullInt64
+		trending, removed, checked    sql.NullInt64
+		comments, votes, messages     sql.NullString
+	)
+	err := row.Scan(&id, &record.Link, &record.Title, &record.Company, &record.Tag, &record.Author,
+		&record.Description, &record.Hash, &firstSeen, &published, &scraped, &delivered, &trending, &removed,
+		&comments, &votes, &checked, &record.RemovalErrors, &record.RemovalMisses, &messages)
+	if err != nil {
+		return "", Record{}, err
+	}
+
+	record.FirstSeen = time.Unix(0, firstSeen).UTC()
+	if t := timeFromNull(published); t != nil {
+		record.PublishedAt = *t
+	}
+	if t := timeFromNull(scraped); t != nil {
+		record.ScrapedAt = *t
+	}
+	record.DeliveredAt = timeFromNull(delivered)
+	record.TrendingAt = timeFromNull(trending)
+	record.RemovedAt = timeFromNull(removed)
+	record.RemovalCheckedAt = timeFromNull(checked)
+
+	if comments.Valid {
+		if err := json.Unmarshal([]byte(comments.String), &record.Comments); err != nil {
+			return "", Record{}, err
+		}
+	}
+	if votes.Valid {
+		if err := json.Unmarshal([]byte(votes.String), &record.Votes); err != nil {
+			return "", Record{}, err
+		}
+	}
+	if messages.Valid {
+		if err := json.Unmarshal([]byte(messages.String), &record.Messages); err != nil {
+			return "", Record{}, err
+		}
+	}
+	return id, record, nil
+}
+
+func nullTime(t *time.Time) sql.NullInt64 {
+	if t == nil || t.IsZero() {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: t.UnixNano(), Valid: true}
+}
+
+func timeFromNull(n sql.NullInt64) *time.Time {
+	if !n.Valid {
+		return nil
+	}
+	t := time.Unix(0, n.Int64).UTC()
+	return &t
+}
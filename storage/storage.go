@@ -2,66 +2,234 @@ package storage
 
 import (
 	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
-	"sync"
+	"strings"
+	"time"
+
+	"github.com/nahidhasan98/deshimula-notifier-unofficial/errorhandling"
 )
 
-type StoryStorage struct {
-	filepath string
-	stories  sync.Map
-	mu       sync.Mutex
+// Store keeps the record of every story of one source. A missing story is
+// not an error, lookups only fail when the store cannot be read.
+type Store interface {
+	Has(id string) (bool, error)
+	Get(id string) (Record, bool, error)
+	// Add marks a story as seen, keeping its record if it exists
+	Add(id string) error
+	// Save stores the record of a story, replacing an existing one
+	Save(id string, record Record) error
+	List() (map[string]Record, error)
+	Delete(id string) error
+	Close() error
 }
 
-func NewStoryStorage(storagePath string) (*StoryStorage, error) {
-	if err := os.MkdirAll(filepath.Dir(storagePath), 0755); err != nil {
+// Backends selectable by Open
+const (
+	JSONBackend   = "json"
+	BoltBackend   = "bolt"
+	SQLiteBackend = "sqlite"
+	MemoryBackend = "memory"
+)
+
+// Database files shared by every source of a backend
+const (
+	boltFile   = "stories.bolt"
+	sqliteFile = "stories.db"
+	// lockFile is held by the process writing the stores, see Lock
+	lockFile = ".lock"
+)
+
+// ErrLocked is returned by Lock while another process uses the storage
+var ErrLocked = errors.New("storage is in use by another process")
+
+// DirLock is the lock of a storage directory
+type DirLock struct {
+	file *os.File
+}
+
+// Open opens the store of a source in dir. The JSON backend (the default)
+// keeps one file per source, the database backends keep every source in one
+// file and import the JSON file of a source they have no records of yet, so
+// switching backends keeps the seen stories.
+func Open(backend string, dir string, storageFile string, source string) (Store, error) {
+	jsonPath := filepath.Join(dir, storageFile)
+	source = strings.ToLower(source)
+
+	var store Store
+	var err error
+	switch backend {
+	case "", JSONBackend:
+		return NewJSONStore(jsonPath)
+	case MemoryBackend:
+		return NewMemoryStore(), nil
+	case BoltBackend:
+		store, err = OpenBolt(filepath.Join(dir, boltFile), source)
+	case SQLiteBackend:
+		store, err = OpenSQLite(filepath.Join(dir, sqliteFile), source)
+	default:
+		return nil, errorhandling.NewError(errorhandling.ConfigError, "Unknown storage backend: "+backend, nil)
+	}
+	if err != nil {
 		return nil, err
 	}
 
-	s := &StoryStorage{
-		filepath: storagePath,
+	if err := importJSON(jsonPath, store); err != nil {
+		store.Close()
+		return nil, err
 	}
+	return store, nil
+}
 
-	if _, err := os.Stat(storagePath); err == nil {
-		data, err := os.ReadFile(storagePath)
-		if err != nil {
-			return nil, err
-		}
+// OpenReadOnly reads the records of a source like Open without writing
+// anything: no log compaction, backup restore, migration or JSON import. The
+// records are copied into a MemoryStore whose changes are not written back,
+// for dry runs.
+func OpenReadOnly(backend string, dir string, storageFile string, source string) (Store, error) {
+	jsonPath := filepath.Join(dir, storageFile)
+	source = strings.ToLower(source)
 
-		var stories map[string]bool
-		if err := json.Unmarshal(data, &stories); err != nil {
-			return nil, err
-		}
+	var stories map[string]Record
+	var err error
+	switch backend {
+	case "", JSONBackend:
+		stories, err = readJSONStore(jsonPath)
+	case MemoryBackend:
+		return NewMemoryStore(), nil
+	case BoltBackend:
+		stories, err = readBolt(filepath.Join(dir, boltFile), source)
+	case SQLiteBackend:
+		stories, err = readSQLite(filepath.Join(dir, sqliteFile), source)
+	default:
+		return nil, errorhandling.NewError(errorhandling.ConfigError, "Unknown storage backend: "+backend, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
 
-		for link := range stories {
-			s.stories.Store(link, true)
+	// Open would import the JSON file into an empty database
+	if len(stories) == 0 && backend != "" && backend != JSONBackend {
+		if _, statErr := os.Stat(jsonPath); statErr == nil {
+			if stories, err = readJSONStore(jsonPath); err != nil {
+				return nil, err
+			}
 		}
 	}
 
-	return s, nil
+	store := NewMemoryStore()
+	for id, record := range stories {
+		store.stories[id] = record
+	}
+	return store, nil
 }
 
-func (s *StoryStorage) HasStory(link string) bool {
-	_, exists := s.stories.Load(link)
-	return exists
+// importJSON copies the JSON file of a source into an empty store
+func importJSON(jsonPath string, store Store) error {
+	stories, err := store.List()
+	if err != nil || len(stories) > 0 {
+		return err
+	}
+	if _, err := os.Stat(jsonPath); err != nil {
+		return nil
+	}
+
+	legacy, err := NewJSONStore(jsonPath)
+	if err != nil {
+		return err
+	}
+	defer legacy.Close()
+	return Migrate(legacy, store)
+}
+
+// Migrate copies every record of from into to
+func Migrate(from, to Store) error {
+	stories, err := from.List()
+	if err != nil {
+		return err
+	}
+	for id, record := range stories {
+		if err := to.Save(id, record); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (s *StoryStorage) AddStory(link string) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// Record is what the storage keeps per story
+type Record struct {
+	// Hash is the content hash of the story when it was last fetched
+	Hash string `json:"hash,omitempty"`
+	// The fields the hash was computed from, kept to diff later edits against
+	Title       string    `json:"title,omitempty"`
+	Company     string    `json:"company,omitempty"`
+	Tag         string    `json:"tag,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Author      string    `json:"author,omitempty"`
+	FirstSeen   time.Time `json:"first_seen"`
+	// PublishedAt is the publish date shown on the site, if any
+	PublishedAt time.Time `json:"published_at,omitzero"`
+	// ScrapedAt is when the story was last fetched
+	ScrapedAt time.Time `json:"scraped_at,omitzero"`
+	// DeliveredAt is set once the story was sent to the notifiers, stories
+	// only marked as seen (first run, backfill) have none
+	DeliveredAt *time.Time `json:"delivered_at,omitempty"`
+	// Messages maps notifier names to the message the story was sent as,
+	// follow-ups are posted as replies to it
+	Messages map[string]string `json:"messages,omitempty"`
+	// Link is the story URL, used to re-fetch stories not on the list page
+	Link string `json:"link,omitempty"`
+	// Comments are the comments already sent
+	Comments []Comment `json:"comments,omitempty"`
+	// Votes is the vote count time series of the re-fetches
+	Votes []VoteSample `json:"votes,omitempty"`
+	// TrendingAt is set once a trending event was sent
+	TrendingAt *time.Time `json:"trending_at,omitempty"`
+	// RemovedAt is set once the story was found to be taken down
+	RemovedAt *time.Time `json:"removed_at,omitempty"`
+	// RemovalCheckedAt is when the story was last re-fetched to see whether
+	// it was taken down
+	RemovalCheckedAt *time.Time `json:"removal_checked_at,omitempty"`
+	// RemovalErrors counts the removal checks in a row that failed for
+	// another reason than the story being gone
+	RemovalErrors int `json:"removal_errors,omitempty"`
+	// RemovalMisses counts the removal checks in a row that loaded the page
+	// without the story
+	RemovalMisses int `json:"removal_misses,omitempty"`
+}
 
-	s.stories.Store(link, true)
+// Posted returns the publish date of the story, or when it was first seen
+// if the site shows none
+func (r Record) Posted() time.Time {
+	if !r.PublishedAt.IsZero() {
+		return r.PublishedAt
+	}
+	return r.FirstSeen
+}
 
-	stories := make(map[string]bool)
-	s.stories.Range(func(key, value interface{}) bool {
-		stories[key.(string)] = true
-		return true
-	})
+// Comment is a reply posted under a story
+type Comment struct {
+	// Key identifies the comment, see base.Comment.Key
+	Key      string    `json:"key"`
+	Author   string    `json:"author,omitempty"`
+	Text     string    `json:"text,omitempty"`
+	PostedAt time.Time `json:"posted_at,omitzero"`
+}
 
-	data, err := json.Marshal(stories)
-	if err != nil {
-		return err
+// UnmarshalJSON also reads the bare keys older versions stored instead of
+// the comments
+func (c *Comment) UnmarshalJSON(data []byte) error {
+	var key string
+	if err := json.Unmarshal(data, &key); err == nil {
+		*c = Comment{Key: key}
+		return nil
 	}
+	type comment Comment
+	return json.Unmarshal(data, (*comment)(c))
+}
 
-	return os.WriteFile(s.filepath, data, 0644)
+// VoteSample is the vote count of a story at one point in time
+type VoteSample struct {
+	At    time.Time `json:"at"`
+	Count int       `json:"count"`
 }
@@ -0,0 +1,365 @@
+package storage
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var backends = []string{JSONBackend, BoltBackend, SQLiteBackend, MemoryBackend}
+
+func TestStoreRoundTrip(t *testing.T) {
+	published := time.Date(2024, 3, 1, 4, 0, 0, 0, time.UTC)
+	delivered := time.Date(2024, 3, 1, 5, 0, 0, 0, time.UTC)
+	record := Record{
+		Hash:        "hash",
+		Title:       "Title",
+		Company:     "Company",
+		Tag:         "Tag",
+		Description: "Description",
+		Author:      "Author",
+		FirstSeen:   delivered,
+		PublishedAt: published,
+		DeliveredAt: &delivered,
+		Messages:    map[string]string{"telegram": "42"},
+		Link:        "https://example.com/story/1",
+		Comments: []Comment{
+			{Key: "a", Author: "Author", Text: "First", PostedAt: published},
+			{Key: "b", Text: "Second"},
+		},
+		Votes: []VoteSample{{At: delivered, Count: 3}},
+		// Removal checks that loaded the page without the story
+		RemovalMisses: 2,
+	}
+
+	for _, backend := range backends {
+		t.Run(backend, func(t *testing.T) {
+			dir := t.TempDir()
+			store, err := Open(backend, dir, "mula_sent_stories.json", "mula")
+			if err != nil {
+				t.Fatalf("Open: %v", err)
+			}
+
+			if mustHave(t, store, "1") {
+				t.Fatal("empty store has story 1")
+			}
+			if err := store.Add("1"); err != nil {
+				t.Fatalf("Add: %v", err)
+			}
+			if seen, exists := mustGet(t, store, "1"); !exists || seen.FirstSeen.IsZero() {
+				t.Fatalf("Get after Add = %+v, %v, want a first seen date", seen, exists)
+			}
+
+			if err := store.Save("1", record); err != nil {
+				t.Fatalf("Save: %v", err)
+			}
+			// Adding a stored story keeps its record
+			if err := store.Add("1"); err != nil {
+				t.Fatalf("Add existing: %v", err)
+			}
+			if err := store.Save("2", Record{Title: "Second"}); err != nil {
+				t.Fatalf("Save: %v", err)
+			}
+			if err := store.Delete("2"); err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+			if mustHave(t, store, "2") {
+				t.Error("deleted story 2 is still stored")
+			}
+
+			if backend != MemoryBackend {
+				if err := store.Close(); err != nil {
+					t.Fatalf("Close: %v", err)
+				}
+				if store, err = Open(backend, dir, "mula_sent_stories.json", "mula"); err != nil {
+					t.Fatalf("reopen: %v", err)
+				}
+			}
+			defer store.Close()
+
+			stories, err := store.List()
+			if err != nil {
+				t.Fatalf("List: %v", err)
+			}
+			if len(stories) != 1 {
+				t.Fatalf("List returned %d stories, want 1", len(stories))
+			}
+			assertRecord(t, stories["1"], record)
+		})
+	}
+}
+
+func TestStoreSourcesShareDatabase(t *testing.T) {
+	for _, backend := range []string{BoltBackend, SQLiteBackend} {
+		t.Run(backend, func(t *testing.T) {
+			dir := t.TempDir()
+			mula, err := Open(backend, dir, "mula_sent_stories.json", "mula")
+			if err != nil {
+				t.Fatalf("Open mula: %v", err)
+			}
+			defer mula.Close()
+			oak, err := Open(backend, dir, "oak_sent_stories.json", "oak")
+			if err != nil {
+				t.Fatalf("Open oak: %v", err)
+			}
+			defer oak.Close()
+
+			if err := mula.Add("1"); err != nil {
+				t.Fatalf("Add: %v", err)
+			}
+			if mustHave(t, oak, "1") {
+				t.Error("story of mula is visible to oak")
+			}
+		})
+	}
+}
+
+func TestStoreImportsJSONFile(t *testing.T) {
+	for _, backend := range []string{BoltBackend, SQLiteBackend} {
+		t.Run(backend, func(t *testing.T) {
+			dir := t.TempDir()
+			legacy, err := NewJSONStore(filepath.Join(dir, "mula_sent_stories.json"))
+			if err != nil {
+				t.Fatalf("NewJSONStore: %v", err)
+			}
+			if err := legacy.Save("1", Record{Title: "Title"}); err != nil {
+				t.Fatalf("Save: %v", err)
+			}
+			if err := legacy.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+
+			store, err := Open(backend, dir, "mula_sent_stories.json", "mula")
+			if err != nil {
+				t.Fatalf("Open: %v", err)
+			}
+			defer store.Close()
+
+			if record, exists := mustGet(t, store, "1"); !exists || record.Title != "Title" {
+				t.Errorf("imported story = %+v, %v, want title %q", record, exists, "Title")
+			}
+		})
+	}
+}
+
+func TestJSONStoreRestoresBackup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stories.json")
+
+	store, err := NewJSONStore(path)
+	if err != nil {
+		t.Fatalf("NewJSONStore: %v", err)
+	}
+	if err := store.Save("1", Record{Title: "Title"}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	// The first snapshot is also backed up
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if _, err := os.Stat(backupPath(path, 1)); err != nil {
+		t.Fatalf("no backup written: %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"1": {"title": `), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err = NewJSONStore(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer store.Close()
+
+	if record, exists := mustGet(t, store, "1"); !exists || record.Title != "Title" {
+		t.Errorf("restored story = %+v, %v, want title %q", record, exists, "Title")
+	}
+	corrupt, _ := filepath.Glob(path + ".corrupt-*")
+	if len(corrupt) != 1 {
+		t.Errorf("corrupted file kept as %v, want one .corrupt- file", corrupt)
+	}
+}
+
+func TestJSONStoreReadsLegacyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stories.json")
+	if err := os.WriteFile(path, []byte(`{"1": true}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := NewJSONStore(path)
+	if err != nil {
+		t.Fatalf("NewJSONStore: %v", err)
+	}
+	defer store.Close()
+
+	if !mustHave(t, store, "1") {
+		t.Error("story of a legacy file is missing")
+	}
+}
+
+// assertRecord compares the fields every backend keeps
+func assertRecord(t *testing.T, got, want Record) {
+	t.Helper()
+
+	if got.Hash != want.Hash || got.Title != want.Title || got.Company != want.Company ||
+		got.Tag != want.Tag || got.Description != want.Description || got.Author != want.Author ||
+		got.Link != want.Link {
+		t.Errorf("record = %+v, want %+v", got, want)
+	}
+	if !got.FirstSeen.Equal(want.FirstSeen) || !got.PublishedAt.Equal(want.PublishedAt) {
+		t.Errorf("dates = %v, %v, want %v, %v", got.FirstSeen, got.PublishedAt, want.FirstSeen, want.PublishedAt)
+	}
+	if got.DeliveredAt == nil || !got.DeliveredAt.Equal(*want.DeliveredAt) {
+		t.Errorf("delivered at %v, want %v", got.DeliveredAt, want.DeliveredAt)
+	}
+	if got.RemovalMisses != want.RemovalMisses {
+		t.Errorf("removal misses = %d, want %d", got.RemovalMisses, want.RemovalMisses)
+	}
+	if got.Messages["telegram"] != want.Messages["telegram"] {
+		t.Errorf("messages = %v, want %v", got.Messages, want.Messages)
+	}
+	if len(got.Comments) != len(want.Comments) || len(got.Votes) != len(want.Votes) ||
+		got.Votes[0].Count != want.Votes[0].Count {
+		t.Fatalf("comments %v and votes %v, want %v and %v", got.Comments, got.Votes, want.Comments, want.Votes)
+	}
+	for i, comment := range got.Comments {
+		if comment.Key != want.Comments[i].Key || comment.Author != want.Comments[i].Author ||
+			comment.Text != want.Comments[i].Text || !comment.PostedAt.Equal(want.Comments[i].PostedAt) {
+			t.Errorf("comment %d = %+v, want %+v", i, comment, want.Comments[i])
+		}
+	}
+}
+
+func TestRecordReadsCommentKeys(t *testing.T) {
+	// Older versions stored only the keys of the comments
+	var record Record
+	if err := json.Unmarshal([]byte(`{"comments":["a",{"key":"b","author":"Author","text":"Text"}]}`), &record); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	want := []Comment{{Key: "a"}, {Key: "b", Author: "Author", Text: "Text"}}
+	if len(record.Comments) != len(want) || record.Comments[0] != want[0] || record.Comments[1] != want[1] {
+		t.Errorf("comments = %+v, want %+v", record.Comments, want)
+	}
+}
+
+func TestOpenReadOnlyWritesNothing(t *testing.T) {
+	for _, backend := range []string{JSONBackend, BoltBackend, SQLiteBackend} {
+		t.Run(backend, func(t *testing.T) {
+			dir := t.TempDir()
+			store, err := Open(backend, dir, "mula_sent_stories.json", "mula")
+			if err != nil {
+				t.Fatalf("Open: %v", err)
+			}
+			if err := store.Save("1", Record{Title: "Title"}); err != nil {
+				t.Fatalf("Save: %v", err)
+			}
+			if err := store.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+			// Left in the log, Open would compact it
+			if backend == JSONBackend {
+				if err := appendWALEntry(filepath.Join(dir, "mula_sent_stories.json"), "2"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			before := dirState(t, dir)
+
+			readOnly, err := OpenReadOnly(backend, dir, "mula_sent_stories.json", "mula")
+			if err != nil {
+				t.Fatalf("OpenReadOnly: %v", err)
+			}
+			if !mustHave(t, readOnly, "1") || (backend == JSONBackend && !mustHave(t, readOnly, "2")) {
+				t.Error("stored stories are missing")
+			}
+			if err := readOnly.Delete("1"); err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+			readOnly.Close()
+
+			if after := dirState(t, dir); after != before {
+				t.Errorf("storage changed:\n%s\nwant\n%s", after, before)
+			}
+		})
+	}
+}
+
+func TestLockIsExclusive(t *testing.T) {
+	dir := t.TempDir()
+	lock, err := Lock(dir)
+	if err != nil {
+		t.Fatalf("Lock: %v", err)
+	}
+	if _, err := Lock(dir); err != ErrLocked {
+		t.Errorf("second Lock = %v, want ErrLocked", err)
+	}
+	if err := lock.Unlock(); err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+
+	lock, err = Lock(dir)
+	if err != nil {
+		t.Fatalf("Lock after Unlock: %v", err)
+	}
+	lock.Unlock()
+}
+
+// appendWALEntry logs a save of id next to the snapshot at path
+func appendWALEntry(path string, id string) error {
+	wal, err := os.OpenFile(path+".wal", os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer wal.Close()
+
+	_, err = wal.WriteString(`{"op":"save","id":"` + id + `","record":{"first_seen":"2024-03-01T00:00:00Z"}}` + "\n")
+	return err
+}
+
+// dirState lists the files of dir with their size and modification time.
+// SQLite creates its -wal and -shm files even for read-only connections, they
+// hold no records.
+func dirState(t *testing.T, dir string) string {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var state string
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), "-wal") || strings.HasSuffix(entry.Name(), "-shm") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			t.Fatal(err)
+		}
+		state += entry.Name() + " " + info.ModTime().String() + " " + strconv.FormatInt(info.Size(), 10) + "\n"
+	}
+	return state
+}
+
+// mustHave reports whether a store has a story, failing the test when the
+// lookup fails
+func mustHave(t *testing.T, store Store, id string) bool {
+	t.Helper()
+	exists, err := store.Has(id)
+	if err != nil {
+		t.Fatalf("Has(%s): %v", id, err)
+	}
+	return exists
+}
+
+// mustGet returns the record of a story, failing the test when the lookup
+// fails
+func mustGet(t *testing.T, store Store, id string) (Record, bool) {
+	t.Helper()
+	record, exists, err := store.Get(id)
+	if err != nil {
+		t.Fatalf("Get(%s): %v", id, err)
+	}
+	return record, exists
+}